@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	nativeLog "log"
@@ -17,6 +19,7 @@ import (
 	"github.com/ivov/n8n-shortlink/internal/config"
 	"github.com/ivov/n8n-shortlink/internal/db"
 	"github.com/ivov/n8n-shortlink/internal/log"
+	"github.com/ivov/n8n-shortlink/internal/policy"
 	"github.com/ivov/n8n-shortlink/internal/services"
 )
 
@@ -80,19 +83,172 @@ func main() {
 
 	defer db.Close()
 
+	// ------------
+	//    policy
+	// ------------
+
+	urlPolicy, err := policy.NewEngine(cfg.Policy.FilePath, &logger)
+	if err != nil {
+		logger.Fatal(err)
+		os.Exit(1)
+	}
+
+	var resolver *services.RedirectResolver
+	if cfg.Resolver.Enabled {
+		resolver = &services.RedirectResolver{
+			Client:  services.NewGuardedHTTPClient(cfg.Resolver.Timeout),
+			MaxHops: cfg.Resolver.MaxHops,
+			Timeout: cfg.Resolver.Timeout,
+		}
+	}
+
+	// ------------
+	//   secrets
+	// ------------
+
+	if cfg.UnlockSession.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			logger.Fatal(err)
+			os.Exit(1)
+		}
+		cfg.UnlockSession.Secret = hex.EncodeToString(secret)
+		logger.Info("no unlock session secret set, generated one - unlocked shortlinks will lock on restart")
+	}
+
+	// ------------
+	//  encryption
+	// ------------
+
+	keyring, err := services.LoadKeyring(cfg.Encryption.Keys, cfg.Encryption.KeyFilePath)
+	if err != nil {
+		logger.Fatal(err)
+		os.Exit(1)
+	}
+
+	if keyring == nil {
+		logger.Info("no encryption keys set - shortlink content will be stored in plaintext")
+	} else {
+		logger.Info("encrypting shortlink content at rest", log.Str("key_id", keyring.ActiveKeyID()))
+	}
+
 	// ------------
 	//    setup
 	// ------------
 
+	argon2Params := services.Argon2idParams{
+		MemoryKiB:   uint32(cfg.Argon2.MemoryKiB),
+		Iterations:  uint32(cfg.Argon2.Iterations),
+		Parallelism: uint8(cfg.Argon2.Parallelism),
+	}
+
+	visitorHasher := &services.VisitorHasher{DB: db}
+
 	api := &api.API{
-		Config:           &cfg,
-		Logger:           &logger,
-		ShortlinkService: &services.ShortlinkService{DB: db, Logger: &logger},
-		VisitService:     &services.VisitService{DB: db, Logger: &logger},
+		Config: &cfg,
+		Logger: &logger,
+		ShortlinkService: &services.ShortlinkService{
+			DB:       db,
+			Logger:   &logger,
+			Policy:   urlPolicy,
+			Hasher:   &services.Argon2idHasher{Params: argon2Params},
+			KDF:      argon2Params,
+			Keyring:  keyring,
+			Resolver: resolver,
+		},
+		VisitService: &services.VisitService{
+			DB:             db,
+			Logger:         &logger,
+			BatchSize:      cfg.VisitQueue.BatchSize,
+			FlushInterval:  cfg.VisitQueue.FlushInterval,
+			Hasher:         visitorHasher,
+			Broker:         services.NewVisitBroker(64), // visits buffered per live subscriber
+			RetentionDays:  cfg.VisitRetention.Days,
+			PruneChunkSize: cfg.VisitRetention.ChunkSize,
+		},
+		LinkHealthService: &services.LinkHealthService{
+			DB:          db,
+			Logger:      &logger,
+			Client:      services.NewGuardedHTTPClient(cfg.HealthCheck.Timeout),
+			Timeout:     cfg.HealthCheck.Timeout,
+			Concurrency: cfg.HealthCheck.Concurrency,
+			Keyring:     keyring,
+		},
+	}
+
+	if cfg.Privacy.HashCreatorIP {
+		api.ShortlinkService.IPHasher = visitorHasher
+	}
+
+	if *cfg.ReencryptMode {
+		count, err := api.ShortlinkService.ReencryptAll(context.Background())
+		if err != nil {
+			logger.Fatal(err)
+			os.Exit(1)
+		}
+
+		logger.Info("finished re-encrypting shortlinks", log.Int("count", count))
+		os.Exit(0)
 	}
 
 	api.InitMetrics(commitSha)
 
+	// ------------
+	//  bkg jobs
+	// ------------
+
+	bkgCtx, cancelBkgJobs := context.WithCancel(context.Background())
+	defer cancelBkgJobs()
+
+	api.WaitGroup.Add(1)
+	go func() {
+		defer api.WaitGroup.Done()
+		urlPolicy.Watch(bkgCtx, cfg.Policy.ReloadInterval)
+	}()
+
+	if cfg.HealthCheck.Enabled {
+		api.WaitGroup.Add(1)
+		go func() {
+			defer api.WaitGroup.Done()
+			api.LinkHealthService.Start(bkgCtx, cfg.HealthCheck.Interval)
+		}()
+	} else {
+		logger.Info("health check disabled")
+	}
+
+	if cfg.VisitQueue.Enabled {
+		api.VisitService.InitQueue(cfg.VisitQueue.Size)
+		api.WaitGroup.Add(1)
+		go func() {
+			defer api.WaitGroup.Done()
+			api.VisitService.Start(bkgCtx) // drains queue after server shutdown
+		}()
+	} else {
+		logger.Info("visit queue disabled, writing visits on request path")
+	}
+
+	if cfg.VisitRetention.Enabled {
+		api.WaitGroup.Add(1)
+		go func() {
+			defer api.WaitGroup.Done()
+			api.VisitService.StartPruning(bkgCtx, cfg.VisitRetention.Interval)
+		}()
+	} else {
+		logger.Info("visit retention disabled, keeping all visits")
+	}
+
+	api.WaitGroup.Add(1)
+	go func() {
+		defer api.WaitGroup.Done()
+		api.StartMetricsRefresh(bkgCtx, cfg.Metrics.RefreshInterval)
+	}()
+
+	api.WaitGroup.Add(1)
+	go func() {
+		defer api.WaitGroup.Done()
+		api.StartUnlockGuardSweep(bkgCtx, time.Minute)
+	}()
+
 	server := &http.Server{
 		Addr:         cfg.Host + ":" + strconv.Itoa(cfg.Port),
 		Handler:      api.Routes(),
@@ -101,6 +257,8 @@ func main() {
 		WriteTimeout: 10 * time.Second,
 	}
 
+	server.RegisterOnShutdown(api.VisitService.Broker.Close) // end live event streams, which never go idle
+
 	// ------------
 	//   shutdown
 	// ------------
@@ -127,6 +285,8 @@ func main() {
 			shutdownErrorCh <- err
 		}
 
+		cancelBkgJobs()
+
 		api.Logger.Info("waiting for bkg tasks to complete")
 
 		api.WaitGroup.Wait()
@@ -13,6 +13,7 @@ require (
 	github.com/tomasen/realip v0.0.0-20180522021738-f0c99a92ddce
 	go.uber.org/zap v1.27.0
 	golang.org/x/crypto v0.35.0
+	golang.org/x/net v0.35.0
 	golang.org/x/time v0.5.0
 )
 
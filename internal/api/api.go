@@ -17,9 +17,12 @@ type API struct {
 	Config *config.Config
 	Logger *log.Logger
 	// WaitGroup tracks background tasks to wait for during server shutdown.
-	WaitGroup        sync.WaitGroup
-	ShortlinkService *services.ShortlinkService
-	VisitService     *services.VisitService
+	WaitGroup         sync.WaitGroup
+	ShortlinkService  *services.ShortlinkService
+	VisitService      *services.VisitService
+	LinkHealthService *services.LinkHealthService
+
+	unlockGuard unlockGuard
 }
 
 // StaticFileHandler creates a handler serving static files with the correct MIME type
@@ -75,7 +78,20 @@ func (api *API) Routes() http.Handler {
 	})
 
 	r.HandleFunc("POST /shortlink", api.HandlePostShortlink)
+	r.HandleFunc("GET /shortlink/{slug}/meta", api.HandleGetShortlinkMeta)
+	r.HandleFunc("GET /shortlink/{slug}/rules", api.HandleGetShortlinkRules)
+	r.HandleFunc("PUT /shortlink/{slug}/rules", api.HandlePutShortlinkRules)
+	r.HandleFunc("GET /shortlink/{slug}/stats", api.HandleGetShortlinkStats)
+	r.HandleFunc("GET /shortlink/{slug}/events", api.HandleGetShortlinkEvents)
+	r.HandleFunc("GET /shortlink/{slug}/visits.csv", api.HandleGetShortlinkVisits)
+	r.HandleFunc("GET /shortlink/{slug}/visits.ndjson", api.HandleGetShortlinkVisits)
+	r.HandleFunc("POST /shortlink/{slug}/share-tokens", api.HandlePostShortlinkShareTokens)
+	r.HandleFunc("DELETE /shortlink/{slug}/share-tokens", api.HandleDeleteShortlinkShareTokens)
+	r.HandleFunc("GET /admin/shortlinks.csv", api.HandleGetAdminShortlinks)
+	r.HandleFunc("GET /admin/shortlinks.ndjson", api.HandleGetAdminShortlinks)
+	r.HandleFunc("POST /{slug}/unlock", api.HandlePostSlugUnlock)
 	r.HandleFunc("GET /{slug}/view", api.HandleGetSlug)
+	r.HandleFunc("GET /{slug}/preview", api.HandleGetSlug)
 	r.HandleFunc("GET /{slug}", api.HandleGetSlug)
 
 	mw := api.SetupMiddleware()
@@ -1,13 +1,21 @@
 package api_test
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"database/sql"
 	"encoding/base64"
+	"encoding/csv"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -17,11 +25,20 @@ import (
 	"github.com/ivov/n8n-shortlink/internal/db/entities"
 	"github.com/ivov/n8n-shortlink/internal/errors"
 	"github.com/ivov/n8n-shortlink/internal/log"
+	"github.com/ivov/n8n-shortlink/internal/policy"
 	"github.com/ivov/n8n-shortlink/internal/services"
+	"github.com/ivov/n8n-shortlink/internal/useragent"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
 )
 
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
 func TestAPI(t *testing.T) {
 	// ------------------------
 	//         setup
@@ -31,6 +48,9 @@ func TestAPI(t *testing.T) {
 		DB:  struct{ FilePath string }{FilePath: ":memory:"},
 		Env: "testing",
 	}
+	cfg.Redirect.DefaultType = http.StatusMovedPermanently
+	cfg.UnlockSession.Secret = "test-unlock-session-secret"
+	cfg.UnlockSession.TTL = time.Hour
 
 	config.SetupDotDir()
 
@@ -41,11 +61,75 @@ func TestAPI(t *testing.T) {
 	require.NoError(t, err)
 	defer dbConn.Close()
 
+	// stand-in for shortlink destinations, reached by rerouting all outbound requests
+	destinationServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/gone":
+			w.WriteHeader(http.StatusNotFound)
+		case "/hop/ok":
+			http.Redirect(w, r, "https://example.com/landing", http.StatusFound)
+		case "/hop/nested":
+			http.Redirect(w, r, "https://tinyurl.com/hop/ok", http.StatusMovedPermanently)
+		case "/hop/phish":
+			http.Redirect(w, r, "https://evil.com/signin-portal", http.StatusFound)
+		case "/hop/loop":
+			http.Redirect(w, r, "/hop/loop", http.StatusFound)
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+		case "/no-head":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		}
+	}))
+	defer destinationServer.Close()
+
+	destinationClient := &http.Client{
+		Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			r.URL.Scheme = "http"
+			r.URL.Host = destinationServer.Listener.Addr().String()
+			return http.DefaultTransport.RoundTrip(r)
+		}),
+	}
+
+	testArgon2Params := services.Argon2idParams{MemoryKiB: 1024, Iterations: 1, Parallelism: 1} // fast for tests
+
+	testKeys := "test-1:" + base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32)) +
+		",test-2:" + base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, 32))
+
+	keyring, err := services.LoadKeyring(testKeys, "")
+	require.NoError(t, err)
+
+	visitorHasher := &services.VisitorHasher{DB: dbConn}
+
+	urlPolicy, err := policy.NewEngine("", &logger) // default rules
+	require.NoError(t, err)
+
 	api := &api.API{
-		Config:           &cfg,
-		Logger:           &logger,
-		ShortlinkService: &services.ShortlinkService{DB: dbConn, Logger: &logger},
-		VisitService:     &services.VisitService{DB: dbConn, Logger: &logger},
+		Config: &cfg,
+		Logger: &logger,
+		ShortlinkService: &services.ShortlinkService{
+			DB:      dbConn,
+			Logger:  &logger,
+			Policy:  urlPolicy,
+			Hasher:  &services.Argon2idHasher{Params: testArgon2Params},
+			KDF:     testArgon2Params,
+			Keyring: keyring,
+		},
+		VisitService: &services.VisitService{
+			DB:     dbConn,
+			Logger: &logger,
+			Hasher: visitorHasher,
+			Broker: services.NewVisitBroker(16),
+		},
+		LinkHealthService: &services.LinkHealthService{
+			DB:          dbConn,
+			Logger:      &logger,
+			Client:      destinationClient,
+			Timeout:     time.Second,
+			Concurrency: 2,
+			Keyring:     keyring,
+		},
 	}
 
 	api.InitMetrics("test-commit-sha")
@@ -336,105 +420,63 @@ func TestAPI(t *testing.T) {
 	})
 
 	// ------------------------
-	//      custom slug
+	//      redirect type
 	// ------------------------
 
-	t.Run("custom slug", func(t *testing.T) {
-		t.Run("should create custom-slug shortlink and redirect on retrieval", func(t *testing.T) {
-			candidate := entities.Shortlink{
-				Slug:    "my-custom-slug",
-				Kind:    "url",
-				Content: "https://example.org",
-			}
-
-			result := storeShortlink(candidate)
-
-			assert.Equal(t, candidate.Slug, result.Slug)
-			assert.Equal(t, candidate.Kind, result.Kind)
-			assert.Equal(t, candidate.Content, result.Content)
+	t.Run("redirect type", func(t *testing.T) {
+		t.Run("should redirect with per-link redirect type", func(t *testing.T) {
+			for _, redirectType := range []int{
+				http.StatusFound,
+				http.StatusTemporaryRedirect,
+				http.StatusPermanentRedirect,
+			} {
+				candidate := entities.Shortlink{
+					Kind:         "url",
+					Content:      "https://example.com/redirect-type",
+					RedirectType: redirectType,
+				}
 
-			resp, err := noFollowRedirectClient.Get(server.URL + "/" + result.Slug)
-			require.NoError(t, err)
-			defer resp.Body.Close()
+				result := storeShortlink(candidate)
 
-			assert.Equal(t, http.StatusMovedPermanently, resp.StatusCode)
-			assert.Equal(t, candidate.Content, resp.Header.Get("Location"))
-		})
-	})
+				assert.Equal(t, redirectType, result.RedirectType)
 
-	// ------------------------
-	//   creation validation
-	// ------------------------
+				resp, err := noFollowRedirectClient.Get(server.URL + "/" + result.Slug)
+				require.NoError(t, err)
+				defer resp.Body.Close()
 
-	t.Run("creation payload validation", func(t *testing.T) {
-		t.Run("should reject on invalid creation payload", func(t *testing.T) {
-			testCases := []struct {
-				name               string
-				shortlink          entities.Shortlink
-				expectedStatusCode int
-				expectedErrorCode  string
-			}{
-				{
-					name:               "Content as empty string",
-					shortlink:          entities.Shortlink{}, // content defaults to empty string
-					expectedStatusCode: http.StatusBadRequest,
-					expectedErrorCode:  errors.ToCode[errors.ErrContentMalformed],
-				},
-				{
-					name:              "Content as neither URL nor JSON",
-					shortlink:         entities.Shortlink{Content: "not-a-url"},
-					expectedErrorCode: errors.ToCode[errors.ErrContentMalformed],
-				},
-				{
-					name:              "Password too short",
-					shortlink:         entities.Shortlink{Content: "https://example.com", Password: "1234567"},
-					expectedErrorCode: errors.ToCode[errors.ErrPasswordTooShort],
-				},
+				assert.Equal(t, redirectType, resp.StatusCode)
+				assert.Equal(t, candidate.Content, resp.Header.Get("Location"))
 			}
+		})
 
-			for _, tc := range testCases {
-				t.Run(tc.name, func(t *testing.T) {
-					body, err := json.Marshal(tc.shortlink)
-					require.NoError(t, err)
-
-					resp, err := http.Post(server.URL+"/shortlink", "application/json", bytes.NewBuffer(body))
-					require.NoError(t, err)
-					defer resp.Body.Close()
+		t.Run("should default to server-level redirect type", func(t *testing.T) {
+			originalDefault := api.Config.Redirect.DefaultType
+			api.Config.Redirect.DefaultType = http.StatusFound
+			defer func() {
+				api.Config.Redirect.DefaultType = originalDefault
+			}()
 
-					errorResponse := toErrorResponse(resp.Body)
+			result := storeShortlink(entities.Shortlink{
+				Kind:    "url",
+				Content: "https://example.com/default-redirect-type",
+			})
 
-					assert.Equal(t, errorResponse.Error.Code, tc.expectedErrorCode)
-				})
-			}
-		})
+			assert.Equal(t, http.StatusFound, result.RedirectType)
 
-		t.Run("should reject on malformed JSON", func(t *testing.T) {
-			resp, err := http.Post(server.URL+"/shortlink", "application/json", strings.NewReader("create shortlink"))
+			resp, err := noFollowRedirectClient.Get(server.URL + "/" + result.Slug)
 			require.NoError(t, err)
 			defer resp.Body.Close()
 
-			assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
-
-			errorResponse := toErrorResponse(resp.Body)
-			assert.Equal(t, errors.ToCode[errors.ErrContentMalformed], errorResponse.Error.Code)
+			assert.Equal(t, http.StatusFound, resp.StatusCode)
 		})
 
-		t.Run("should reject on duplicate custom slug in payload", func(t *testing.T) {
-			candidate := entities.Shortlink{
-				Slug:    "some-custom-slug",
-				Kind:    "url",
-				Content: "https://example.com",
-			}
-
-			result := storeShortlink(candidate)
-
-			duplicate := entities.Shortlink{
-				Slug:    result.Slug, // already exists
-				Content: "https://other-example.com",
-			}
-
-			body, err := json.Marshal(duplicate)
+		t.Run("should reject unsupported redirect type", func(t *testing.T) {
+			body, err := json.Marshal(entities.Shortlink{
+				Content:      "https://example.com",
+				RedirectType: http.StatusSeeOther,
+			})
 			require.NoError(t, err)
+
 			resp, err := http.Post(server.URL+"/shortlink", "application/json", bytes.NewBuffer(body))
 			require.NoError(t, err)
 			defer resp.Body.Close()
@@ -442,54 +484,73 @@ func TestAPI(t *testing.T) {
 			assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
 
 			errorResponse := toErrorResponse(resp.Body)
-			assert.Equal(t, errors.ToCode[errors.ErrSlugTaken], errorResponse.Error.Code)
+			assert.Equal(t, errors.ToCode[errors.ErrRedirectTypeUnsupported], errorResponse.Error.Code)
+		})
+	})
 
-			// verify that original shortlink is still intact
-			resp, err = noFollowRedirectClient.Get(server.URL + "/" + candidate.Slug)
+	// ------------------------
+	//    query passthrough
+	// ------------------------
+
+	t.Run("query passthrough", func(t *testing.T) {
+		getLocation := func(path string) string {
+			resp, err := noFollowRedirectClient.Get(server.URL + path)
 			require.NoError(t, err)
 			defer resp.Body.Close()
 
 			assert.Equal(t, http.StatusMovedPermanently, resp.StatusCode)
-			assert.Equal(t, "https://example.com", resp.Header.Get("Location"))
-		})
 
-		t.Run("should reject on invalid custom slug", func(t *testing.T) {
-			testCases := []struct {
-				errorCode string
-				slug      string
-			}{
-				{errors.ToCode[errors.ErrSlugTooShort], "abc"},
-				{errors.ToCode[errors.ErrSlugTooLong], strings.Repeat("a", 513)},
-				{errors.ToCode[errors.ErrSlugMisformatted], "abc+def"},
-				{errors.ToCode[errors.ErrSlugReserved], "health"},
-			}
+			return resp.Header.Get("Location")
+		}
 
-			for _, tc := range testCases {
-				shortlink := entities.Shortlink{
-					Slug:    tc.slug,
-					Content: "https://example.com",
-				}
+		t.Run("should drop visitor query params by default", func(t *testing.T) {
+			result := storeShortlink(entities.Shortlink{
+				Kind:    "url",
+				Content: "https://example.com/no-forwarding",
+			})
 
-				body, _ := json.Marshal(shortlink)
-				resp, err := http.Post(server.URL+"/shortlink", "application/json", bytes.NewBuffer(body))
-				require.NoError(t, err)
-				defer resp.Body.Close()
+			location := getLocation("/" + result.Slug + "?utm_source=newsletter")
 
-				assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+			assert.Equal(t, "https://example.com/no-forwarding", location)
+		})
 
-				errorResponse := toErrorResponse(resp.Body)
+		t.Run("should merge visitor query params with destination params", func(t *testing.T) {
+			result := storeShortlink(entities.Shortlink{
+				Kind:         "url",
+				Content:      "https://example.com/forwarding?lang=en",
+				ForwardQuery: true,
+			})
 
-				assert.Equal(t, errorResponse.Error.Code, tc.errorCode)
-			}
+			location := getLocation("/" + result.Slug + "?utm_source=newsletter&lang=de")
+
+			assert.Equal(t, "https://example.com/forwarding?lang=en&utm_source=newsletter", location)
 		})
 
-		t.Run("should reject payload >= 5 MB", func(t *testing.T) {
-			tooBig := strings.Repeat("a", 5*1024*1024) // 5 MB
-			tooBigCandidate := entities.Shortlink{
-				Content: "https://example.com?" + tooBig,
-			}
+		t.Run("should append UTM params without overriding visitor params", func(t *testing.T) {
+			result := storeShortlink(entities.Shortlink{
+				Kind:         "url",
+				Content:      "https://example.com/utm",
+				ForwardQuery: true,
+				UTMParams: entities.StringMap{
+					"utm_source": "n8n.to",
+					"utm_medium": "shortlink",
+				},
+			})
 
-			body, err := json.Marshal(tooBigCandidate)
+			assert.Equal(t, "n8n.to", result.UTMParams["utm_source"])
+
+			location := getLocation("/" + result.Slug)
+			assert.Equal(t, "https://example.com/utm?utm_medium=shortlink&utm_source=n8n.to", location)
+
+			location = getLocation("/" + result.Slug + "?utm_source=newsletter")
+			assert.Equal(t, "https://example.com/utm?utm_medium=shortlink&utm_source=newsletter", location)
+		})
+
+		t.Run("should reject unknown UTM params", func(t *testing.T) {
+			body, err := json.Marshal(entities.Shortlink{
+				Content:   "https://example.com",
+				UTMParams: entities.StringMap{"utm_foo": "bar"},
+			})
 			require.NoError(t, err)
 
 			resp, err := http.Post(server.URL+"/shortlink", "application/json", bytes.NewBuffer(body))
@@ -499,215 +560,2818 @@ func TestAPI(t *testing.T) {
 			assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
 
 			errorResponse := toErrorResponse(resp.Body)
-			assert.Equal(t, errorResponse.Error.Code, errors.ToCode[errors.ErrPayloadTooLarge])
-
-			// retry with smaller payload
-
-			rightSized := strings.Repeat("a", 4*1024*1024) // 4 MB
-			rightSizedCandidate := entities.Shortlink{
-				Kind:    "url",
-				Content: "https://example.com?" + rightSized,
-			}
-
-			result := storeShortlink(rightSizedCandidate)
-
-			assert.NotEmpty(t, result.Slug)
-			assert.Equal(t, rightSizedCandidate.Kind, result.Kind)
-			assert.Equal(t, rightSizedCandidate.Content, result.Content)
+			assert.Equal(t, errors.ToCode[errors.ErrUTMParamsInvalid], errorResponse.Error.Code)
 		})
 	})
 
-	t.Run("rate limiting", func(t *testing.T) {
-		t.Run("should enforce rate limiting", func(t *testing.T) {
-			// enable rate limiting only for this test
-			originalConfig := *api.Config
-			api.Config.RateLimiter.Enabled = true
-			api.Config.RateLimiter.RPS = 2
-			api.Config.RateLimiter.Burst = 2
-			defer func() {
-				api.Config = &originalConfig
-			}()
-
-			shortlink := entities.Shortlink{
-				Content: "https://example.com",
-			}
-			body, err := json.Marshal(shortlink)
-			require.NoError(t, err)
-
-			// util to make a request
-			makeRequest := func() (*http.Response, error) {
-				return http.Post(server.URL+"/shortlink", "application/json", bytes.NewBuffer(body))
-			}
+	// ------------------------
+	//        preview
+	// ------------------------
 
-			// make requests up to the limit
-			for i := 0; i < api.Config.RateLimiter.Burst; i++ {
-				resp, err := makeRequest()
-				require.NoError(t, err)
-				defer resp.Body.Close()
-				assert.Equal(t, http.StatusCreated, resp.StatusCode)
-			}
+	t.Run("preview", func(t *testing.T) {
+		assertPreviewShown := func(resp *http.Response, destination string) {
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, "text/html; charset=utf-8", resp.Header.Get("Content-Type"))
 
-			resp, err := makeRequest() // should be rate limited
+			bodyBytes, err := io.ReadAll(resp.Body)
 			require.NoError(t, err)
-			defer resp.Body.Close()
-			assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
-
-			errorResponse := toErrorResponse(resp.Body)
+			bodyString := string(bodyBytes)
 
-			assert.Equal(t, "You have exceeded the rate limit. Please wait and retry later.", errorResponse.Error.Message)
+			assert.Contains(t, bodyString, "example.com")
+			assert.Contains(t, bodyString, `href="`+destination+`"`)
+			assert.Contains(t, bodyString, "Continue")
+		}
 
-			time.Sleep(time.Second) // wait for rate limit to reset
+		t.Run("should show preview for URL shortlink", func(t *testing.T) {
+			result := storeShortlink(entities.Shortlink{
+				Kind:    "url",
+				Content: "https://example.com/preview-test",
+			})
 
-			resp, err = makeRequest() // should succeed
+			resp, err := noFollowRedirectClient.Get(server.URL + "/" + result.Slug + "/preview")
 			require.NoError(t, err)
 			defer resp.Body.Close()
-			assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+			assertPreviewShown(resp, "https://example.com/preview-test")
 		})
-	})
 
-	t.Run("password protection", func(t *testing.T) {
-		t.Run("should store password-protected shortlink", func(t *testing.T) {
-			candidate := entities.Shortlink{
-				Kind:     "url",
-				Content:  "https://example.com",
-				Password: "securepass123",
-			}
+		t.Run("should show preview on every visit if forced", func(t *testing.T) {
+			result := storeShortlink(entities.Shortlink{
+				Kind:         "url",
+				Content:      "https://example.com/forced-preview-test",
+				ForcePreview: true,
+			})
 
-			result := storeShortlink(candidate)
+			assert.True(t, result.ForcePreview)
 
-			var storedPassword string
-			err = dbConn.Get(&storedPassword, "SELECT password FROM shortlinks WHERE slug = ?", result.Slug)
+			resp, err := noFollowRedirectClient.Get(server.URL + "/" + result.Slug)
 			require.NoError(t, err)
-			assert.NotEmpty(t, storedPassword)
-			assert.NotContains(t, storedPassword, "securepass123") // has been hashed
+			defer resp.Body.Close()
 
-			assert.NotEmpty(t, result.Slug)
-			assert.Equal(t, candidate.Kind, result.Kind)
-			assert.Equal(t, candidate.Content, result.Content)
-			assert.Empty(t, result.Password) // not returned in response
+			assertPreviewShown(resp, "https://example.com/forced-preview-test")
 		})
 
-		t.Run("should show challenge for password-protected shortlink", func(t *testing.T) {
+		t.Run("should return 404 on preview of workflow shortlink", func(t *testing.T) {
 			result := storeShortlink(entities.Shortlink{
-				Kind:     "url",
-				Content:  "https://example.com/protected",
-				Password: "securepass123",
+				Kind:    "workflow",
+				Content: `{"nodes":[]}`,
 			})
 
-			resp, err := http.Get(server.URL + "/" + result.Slug)
+			resp, err := http.Get(server.URL + "/" + result.Slug + "/preview")
 			require.NoError(t, err)
 			defer resp.Body.Close()
 
-			assertChallengeShown(resp)
+			assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		})
+	})
+
+	// ------------------------
+	//       link health
+	// ------------------------
+
+	t.Run("link health", func(t *testing.T) {
+		type MetaResponse struct {
+			Data struct {
+				Slug   string               `json:"slug"`
+				Kind   string               `json:"kind"`
+				Health *entities.LinkHealth `json:"health"`
+			} `json:"data"`
+		}
+
+		getMeta := func(shortlink entities.Shortlink) MetaResponse {
+			req, err := http.NewRequest("GET", server.URL+"/shortlink/"+shortlink.Slug+"/meta", nil)
+			require.NoError(t, err)
+			req.Header.Set("Authorization", "Bearer "+shortlink.ManagementToken)
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+			var meta MetaResponse
+			err = json.NewDecoder(resp.Body).Decode(&meta)
+			require.NoError(t, err)
+
+			return meta
+		}
+
+		ok := storeShortlink(entities.Shortlink{Kind: "url", Content: "https://example.com/ok"})
+		gone := storeShortlink(entities.Shortlink{Kind: "url", Content: "https://example.com/gone"})
+		noHead := storeShortlink(entities.Shortlink{Kind: "url", Content: "https://example.com/no-head"})
+
+		t.Run("should report unchecked shortlink", func(t *testing.T) {
+			meta := getMeta(ok)
+
+			assert.Equal(t, ok.Slug, meta.Data.Slug)
+			assert.Equal(t, "url", meta.Data.Kind)
+			assert.Nil(t, meta.Data.Health)
+		})
+
+		t.Run("should record destination health", func(t *testing.T) {
+			err := api.LinkHealthService.CheckAll(context.Background())
+			require.NoError(t, err)
+
+			meta := getMeta(ok)
+			require.NotNil(t, meta.Data.Health)
+			assert.False(t, meta.Data.Health.IsBroken)
+			assert.Equal(t, http.StatusOK, *meta.Data.Health.StatusCode)
+			assert.NotZero(t, meta.Data.Health.CheckedAt)
+
+			meta = getMeta(gone)
+			require.NotNil(t, meta.Data.Health)
+			assert.True(t, meta.Data.Health.IsBroken)
+			assert.Equal(t, http.StatusNotFound, *meta.Data.Health.StatusCode)
+
+			meta = getMeta(noHead) // falls back to GET
+			require.NotNil(t, meta.Data.Health)
+			assert.False(t, meta.Data.Health.IsBroken)
+			assert.Equal(t, http.StatusOK, *meta.Data.Health.StatusCode)
+		})
+
+		t.Run("should record timed-out destination as broken", func(t *testing.T) {
+			impatientService := *api.LinkHealthService
+			impatientService.Timeout = 50 * time.Millisecond
+
+			health := impatientService.Check(context.Background(), "https://example.com/slow")
+
+			assert.True(t, health.IsBroken)
+			assert.Nil(t, health.StatusCode)
+			assert.NotNil(t, health.Error)
+		})
+
+		t.Run("should expose broken shortlinks gauge on refresh", func(t *testing.T) {
+			require.NoError(t, api.RefreshGauges())
+
+			resp, err := http.Get(server.URL + "/metrics")
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			assert.Contains(t, string(body), "broken_shortlinks 1")
+		})
+
+		t.Run("should record broken variant destination", func(t *testing.T) {
+			split := storeShortlink(entities.Shortlink{
+				Kind:    "url",
+				Content: "https://example.com/ok",
+				Variants: entities.Variants{
+					{Name: "a", Destination: "https://example.com/ok", Weight: 1},
+					{Name: "b", Destination: "https://example.com/gone", Weight: 1},
+				},
+			})
+
+			require.NoError(t, api.LinkHealthService.CheckAll(context.Background()))
+
+			meta := getMeta(split)
+			require.NotNil(t, meta.Data.Health)
+			assert.True(t, meta.Data.Health.IsBroken)
+			assert.Equal(t, http.StatusNotFound, *meta.Data.Health.StatusCode)
+			require.NotNil(t, meta.Data.Health.Error)
+			assert.Contains(t, *meta.Data.Health.Error, "https://example.com/gone")
+		})
+
+		t.Run("should reject meta without valid token", func(t *testing.T) {
+			resp, err := http.Get(server.URL + "/shortlink/" + gone.Slug + "/meta")
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+			assert.Equal(t, errors.ToCode[errors.ErrAuthHeaderMissing], toErrorResponse(resp.Body).Error.Code)
+		})
+
+		t.Run("should return 404 for meta of inexistent slug", func(t *testing.T) {
+			resp, err := http.Get(server.URL + "/shortlink/inexistent/meta")
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		})
+	})
+
+	// ------------------------
+	//     redirect chains
+	// ------------------------
+
+	t.Run("redirect chains", func(t *testing.T) {
+		api.ShortlinkService.Resolver = &services.RedirectResolver{
+			Client:  destinationClient,
+			MaxHops: 3,
+			Timeout: time.Second,
+		}
+		defer func() {
+			api.ShortlinkService.Resolver = nil
+		}()
+
+		postShortlink := func(content string) *http.Response {
+			body, err := json.Marshal(entities.Shortlink{Content: content})
+			require.NoError(t, err)
+
+			resp, err := http.Post(server.URL+"/shortlink", "application/json", bytes.NewBuffer(body))
+			require.NoError(t, err)
+
+			return resp
+		}
+
+		t.Run("should store resolved chain through nested shorteners", func(t *testing.T) {
+			result := storeShortlink(entities.Shortlink{Kind: "url", Content: "https://bit.ly/hop/nested"})
+
+			var chain entities.StringList
+			err := dbConn.Get(&chain, "SELECT redirect_chain FROM shortlinks WHERE slug = ?", result.Slug)
+			require.NoError(t, err)
+
+			assert.Equal(t, entities.StringList{
+				"https://bit.ly/hop/nested",
+				"https://tinyurl.com/hop/ok",
+				"https://example.com/landing",
+			}, chain)
+		})
+
+		t.Run("should not store chain for URL without redirects", func(t *testing.T) {
+			result := storeShortlink(entities.Shortlink{Kind: "url", Content: "https://example.com/no-redirect"})
+
+			var chain entities.StringList
+			err := dbConn.Get(&chain, "SELECT redirect_chain FROM shortlinks WHERE slug = ?", result.Slug)
+			require.NoError(t, err)
+
+			assert.Nil(t, chain)
+		})
+
+		t.Run("should block chain ending in blocked destination", func(t *testing.T) {
+			resp := postShortlink("https://goo.gl/hop/phish")
+			defer resp.Body.Close()
+
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+			errorResponse := toErrorResponse(resp.Body)
+			assert.Equal(t, errors.ToCode[errors.ErrContentBlocked], errorResponse.Error.Code)
+			assert.Contains(t, errorResponse.Error.Trace, "https://evil.com/signin-portal")
+			assert.Contains(t, errorResponse.Error.Trace, "deny_paths:credential-page")
+		})
+
+		t.Run("should resolve destinations in parallel within a single deadline", func(t *testing.T) {
+			variants := entities.Variants{}
+			for i := range 10 {
+				variants = append(variants, entities.Variant{
+					Name:        fmt.Sprintf("v%d", i),
+					Destination: fmt.Sprintf("https://example.com/slow?v=%d", i), // 200ms each
+					Weight:      1,
+				})
+			}
+
+			start := time.Now()
+			storeShortlink(entities.Shortlink{Kind: "url", Content: "https://example.com/slow", Variants: variants})
+
+			assert.Less(t, time.Since(start), time.Second) // 2.2s if resolved one after another
+		})
+
+		t.Run("should block variant chain ending in blocked destination", func(t *testing.T) {
+			body, err := json.Marshal(entities.Shortlink{
+				Content: "https://example.com",
+				Variants: entities.Variants{
+					{Name: "a", Destination: "https://example.com/a", Weight: 1},
+					{Name: "b", Destination: "https://goo.gl/hop/phish", Weight: 1},
+				},
+			})
+			require.NoError(t, err)
+
+			resp, err := http.Post(server.URL+"/shortlink", "application/json", bytes.NewBuffer(body))
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+			assert.Equal(t, errors.ToCode[errors.ErrContentBlocked], toErrorResponse(resp.Body).Error.Code)
+		})
+
+		t.Run("should block chain exceeding max hops", func(t *testing.T) {
+			resp := postShortlink("https://t.co/hop/loop")
+			defer resp.Body.Close()
+
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+			errorResponse := toErrorResponse(resp.Body)
+			assert.Equal(t, errors.ToCode[errors.ErrRedirectChainTooLong], errorResponse.Error.Code)
+		})
+	})
+
+	// ------------------------
+	//      routing rules
+	// ------------------------
+
+	t.Run("routing rules", func(t *testing.T) {
+		const mobileUserAgent = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) Mobile/15E148 Safari/604.1"
+		const desktopUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) Chrome/120.0.0.0 Safari/537.36"
+
+		getLocation := func(slug string, headers map[string]string) string {
+			req, err := http.NewRequest("GET", server.URL+"/"+slug, nil)
+			require.NoError(t, err)
+			for key, value := range headers {
+				req.Header.Set(key, value)
+			}
+
+			resp, err := noFollowRedirectClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, http.StatusMovedPermanently, resp.StatusCode)
+
+			return resp.Header.Get("Location")
+		}
+
+		sendRules := func(method, slug, token string, body string) *http.Response {
+			req, err := http.NewRequest(method, server.URL+"/shortlink/"+slug+"/rules", strings.NewReader(body))
+			require.NoError(t, err)
+			if token != "" {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+
+			return resp
+		}
+
+		result := storeShortlink(entities.Shortlink{
+			Kind:    "url",
+			Content: "https://example.com/docs",
+			RoutingRules: entities.RoutingRules{
+				{Languages: []string{"de"}, Device: "mobile", Destination: "https://example.com/de/mobile-docs"},
+				{Languages: []string{"de"}, Destination: "https://example.com/de/docs"},
+				{Device: "mobile", Destination: "https://example.com/mobile-docs"},
+				{RefererHost: "n8n.io", Destination: "https://example.com/docs?ref=n8n"},
+			},
+		})
+
+		t.Run("should return management token on creation", func(t *testing.T) {
+			assert.NotEmpty(t, result.ManagementToken)
+
+			var storedHash string
+			err := dbConn.Get(&storedHash, "SELECT management_token_hash FROM shortlinks WHERE slug = ?", result.Slug)
+			require.NoError(t, err)
+			assert.NotEqual(t, result.ManagementToken, storedHash)
+		})
+
+		t.Run("should route by language, device and referer host", func(t *testing.T) {
+			testCases := []struct {
+				name     string
+				headers  map[string]string
+				expected string
+			}{
+				{"german mobile", map[string]string{"Accept-Language": "de-DE,de;q=0.9", "User-Agent": mobileUserAgent}, "https://example.com/de/mobile-docs"},
+				{"german desktop", map[string]string{"Accept-Language": "de", "User-Agent": desktopUserAgent}, "https://example.com/de/docs"},
+				{"english mobile", map[string]string{"Accept-Language": "en-US,de;q=0.5", "User-Agent": mobileUserAgent}, "https://example.com/mobile-docs"},
+				{"referer subdomain", map[string]string{"Referer": "https://docs.n8n.io/page", "User-Agent": desktopUserAgent}, "https://example.com/docs?ref=n8n"},
+				{"fallback", map[string]string{"Accept-Language": "en", "User-Agent": desktopUserAgent}, "https://example.com/docs"},
+			}
+
+			for _, tc := range testCases {
+				t.Run(tc.name, func(t *testing.T) {
+					assert.Equal(t, tc.expected, getLocation(result.Slug, tc.headers))
+				})
+			}
+		})
+
+		t.Run("should resolve shortlink predating management tokens", func(t *testing.T) {
+			_, err := dbConn.Exec("INSERT INTO shortlinks (slug, kind, content, password) VALUES ('legacy-url', 'url', 'https://example.com/legacy', '');")
+			require.NoError(t, err)
+
+			resp, err := noFollowRedirectClient.Get(server.URL + "/legacy-url")
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			assert.Equal(t, http.StatusMovedPermanently, resp.StatusCode)
+
+			resp = sendRules("GET", "legacy-url", "", "")
+			defer resp.Body.Close()
+			assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		})
+
+		t.Run("should reject rules management without valid token", func(t *testing.T) {
+			resp := sendRules("GET", result.Slug, "", "")
+			defer resp.Body.Close()
+			assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+			resp = sendRules("PUT", result.Slug, "wrong-token", `{"rules":[]}`)
+			defer resp.Body.Close()
+			assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+			assert.Equal(t, errors.ToCode[errors.ErrManagementTokenInvalid], toErrorResponse(resp.Body).Error.Code)
+		})
+
+		t.Run("should replace and read rules with valid token", func(t *testing.T) {
+			resp := sendRules("PUT", result.Slug, result.ManagementToken,
+				`{"rules":[{"device":"desktop","destination":"https://example.com/desktop-docs"}]}`)
+			defer resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+			resp = sendRules("GET", result.Slug, result.ManagementToken, "")
+			defer resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+			var response struct {
+				Data struct {
+					Rules entities.RoutingRules `json:"rules"`
+				} `json:"data"`
+			}
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+			require.Len(t, response.Data.Rules, 1)
+			assert.Equal(t, "desktop", response.Data.Rules[0].Device)
+
+			assert.Equal(t, "https://example.com/desktop-docs", getLocation(result.Slug, map[string]string{"User-Agent": desktopUserAgent}))
+			assert.Equal(t, "https://example.com/docs", getLocation(result.Slug, map[string]string{"User-Agent": mobileUserAgent}))
+		})
+
+		t.Run("should reject invalid rules", func(t *testing.T) {
+			testCases := []struct {
+				name      string
+				body      string
+				errorCode string
+			}{
+				{"no conditions", `{"rules":[{"destination":"https://example.com"}]}`, errors.ToCode[errors.ErrRoutingRulesInvalid]},
+				{"unsupported device", `{"rules":[{"device":"tv","destination":"https://example.com"}]}`, errors.ToCode[errors.ErrRoutingRulesInvalid]},
+				{"malformed destination", `{"rules":[{"device":"mobile","destination":"not-a-url"}]}`, errors.ToCode[errors.ErrRoutingRulesInvalid]},
+				{"blocked destination", `{"rules":[{"device":"mobile","destination":"https://x.cpanel.site"}]}`, errors.ToCode[errors.ErrContentBlocked]},
+			}
+
+			for _, tc := range testCases {
+				t.Run(tc.name, func(t *testing.T) {
+					resp := sendRules("PUT", result.Slug, result.ManagementToken, tc.body)
+					defer resp.Body.Close()
+
+					assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+					assert.Equal(t, tc.errorCode, toErrorResponse(resp.Body).Error.Code)
+				})
+			}
+		})
+	})
+
+	// ------------------------
+	//      A/B split
+	// ------------------------
+
+	t.Run("A/B split", func(t *testing.T) {
+		variants := entities.Variants{
+			{Name: "control", Destination: "https://example.com/onboarding-a", Weight: 70},
+			{Name: "challenger", Destination: "https://example.com/onboarding-b", Weight: 30},
+		}
+
+		visit := func(slug string, cookies ...*http.Cookie) *http.Response {
+			req, err := http.NewRequest("GET", server.URL+"/"+slug, nil)
+			require.NoError(t, err)
+			for _, cookie := range cookies {
+				req.AddCookie(cookie)
+			}
+
+			resp, err := noFollowRedirectClient.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+
+			assert.Equal(t, http.StatusMovedPermanently, resp.StatusCode)
+
+			return resp
+		}
+
+		t.Run("should split visitors across variants and record them", func(t *testing.T) {
+			result := storeShortlink(entities.Shortlink{Kind: "url", Content: "https://example.com/onboarding", Variants: variants})
+
+			counts := map[string]int{}
+			for range 100 {
+				resp := visit(result.Slug)
+				counts[resp.Header.Get("Location")]++
+				assert.Equal(t, "no-store", resp.Header.Get("Cache-Control"))
+				assert.Empty(t, resp.Cookies())
+			}
+
+			assert.Len(t, counts, 2)
+			assert.Greater(t, counts["https://example.com/onboarding-a"], counts["https://example.com/onboarding-b"])
+
+			var recorded []struct {
+				Variant string `db:"variant"`
+				Count   int    `db:"count"`
+			}
+			err := dbConn.Select(&recorded, "SELECT variant, COUNT(*) AS count FROM visits WHERE slug = ? GROUP BY variant ORDER BY variant;", result.Slug)
+			require.NoError(t, err)
+			require.Len(t, recorded, 2)
+			assert.Equal(t, "challenger", recorded[0].Variant)
+			assert.Equal(t, counts["https://example.com/onboarding-b"], recorded[0].Count)
+			assert.Equal(t, "control", recorded[1].Variant)
+			assert.Equal(t, counts["https://example.com/onboarding-a"], recorded[1].Count)
+		})
+
+		t.Run("should keep visitor on variant with sticky assignment", func(t *testing.T) {
+			result := storeShortlink(entities.Shortlink{
+				Kind:           "url",
+				Content:        "https://example.com/onboarding",
+				Variants:       variants,
+				StickyVariants: true,
+			})
+
+			first := visit(result.Slug)
+			cookies := first.Cookies()
+			require.Len(t, cookies, 1)
+			assert.Equal(t, "/"+result.Slug, cookies[0].Path)
+			assert.True(t, cookies[0].HttpOnly)
+
+			for range 20 {
+				assert.Equal(t, first.Header.Get("Location"), visit(result.Slug, cookies[0]).Header.Get("Location"))
+			}
+
+			stale := &http.Cookie{Name: cookies[0].Name, Value: "removed-variant"}
+			assert.Contains(t, []string{"https://example.com/onboarding-a", "https://example.com/onboarding-b"}, visit(result.Slug, stale).Header.Get("Location"))
+		})
+
+		t.Run("should route by rule before splitting", func(t *testing.T) {
+			result := storeShortlink(entities.Shortlink{
+				Kind:         "url",
+				Content:      "https://example.com/onboarding",
+				Variants:     variants,
+				RoutingRules: entities.RoutingRules{{RefererHost: "n8n.io", Destination: "https://example.com/onboarding-n8n"}},
+			})
+
+			req, err := http.NewRequest("GET", server.URL+"/"+result.Slug, nil)
+			require.NoError(t, err)
+			req.Header.Set("Referer", "https://n8n.io")
+
+			resp, err := noFollowRedirectClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, "https://example.com/onboarding-n8n", resp.Header.Get("Location"))
+
+			var variant string
+			err = dbConn.Get(&variant, "SELECT variant FROM visits WHERE slug = ?;", result.Slug)
+			require.NoError(t, err)
+			assert.Empty(t, variant)
+		})
+
+		t.Run("should reject invalid variants", func(t *testing.T) {
+			testCases := []struct {
+				name      string
+				candidate entities.Shortlink
+				errorCode string
+			}{
+				{
+					"single variant",
+					entities.Shortlink{Content: "https://example.com", Variants: variants[:1]},
+					errors.ToCode[errors.ErrVariantsInvalid],
+				},
+				{
+					"duplicate name",
+					entities.Shortlink{Content: "https://example.com", Variants: entities.Variants{variants[0], variants[0]}},
+					errors.ToCode[errors.ErrVariantsInvalid],
+				},
+				{
+					"zero weight",
+					entities.Shortlink{Content: "https://example.com", Variants: entities.Variants{variants[0], {Name: "b", Destination: "https://example.com/b"}}},
+					errors.ToCode[errors.ErrVariantsInvalid],
+				},
+				{
+					"blocked destination",
+					entities.Shortlink{Content: "https://example.com", Variants: entities.Variants{variants[0], {Name: "b", Destination: "https://x.cpanel.site", Weight: 1}}},
+					errors.ToCode[errors.ErrContentBlocked],
+				},
+				{
+					"workflow kind",
+					entities.Shortlink{Content: `{"nodes":[]}`, Variants: variants},
+					errors.ToCode[errors.ErrVariantsInvalid],
+				},
+			}
+
+			for _, tc := range testCases {
+				t.Run(tc.name, func(t *testing.T) {
+					body, err := json.Marshal(tc.candidate)
+					require.NoError(t, err)
+
+					resp, err := http.Post(server.URL+"/shortlink", "application/json", bytes.NewBuffer(body))
+					require.NoError(t, err)
+					defer resp.Body.Close()
+
+					assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+					assert.Equal(t, tc.errorCode, toErrorResponse(resp.Body).Error.Code)
+				})
+			}
+		})
+	})
+
+	// ------------------------
+	//   scheduled activation
+	// ------------------------
+
+	t.Run("scheduled activation", func(t *testing.T) {
+		countVisits := func(slug string) int {
+			var count int
+			err := dbConn.Get(&count, "SELECT COUNT(*) FROM visits WHERE slug = ?;", slug)
+			require.NoError(t, err)
+			return count
+		}
+
+		berlin := time.FixedZone("CEST", 2*60*60)
+		activateAt := time.Now().Add(time.Hour).In(berlin).Truncate(time.Second)
+
+		result := storeShortlink(entities.Shortlink{
+			Kind:       "url",
+			Content:    "https://example.com/webinar",
+			ActivateAt: &entities.CustomTime{Time: activateAt},
+		})
+
+		t.Run("should store activation time in UTC", func(t *testing.T) {
+			var stored string
+			err := dbConn.Get(&stored, "SELECT activate_at FROM shortlinks WHERE slug = ?;", result.Slug)
+			require.NoError(t, err)
+			assert.Equal(t, activateAt.UTC().Format("2006-01-02 15:04:05"), stored)
+		})
+
+		t.Run("should show not-yet-available page to browsers before activation", func(t *testing.T) {
+			req, err := http.NewRequest("GET", server.URL+"/"+result.Slug, nil)
+			require.NoError(t, err)
+			req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+			resp, err := noFollowRedirectClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+			assert.Equal(t, "no-store", resp.Header.Get("Cache-Control"))
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Contains(t, string(body), "Not yet available")
+			assert.Contains(t, string(body), activateAt.UTC().Format("2 January 2006, 15:04 UTC"))
+			assert.NotContains(t, string(body), "https://example.com/webinar")
+		})
+
+		t.Run("should return 404 to API clients before activation", func(t *testing.T) {
+			for _, path := range []string{"/" + result.Slug, "/" + result.Slug + "/preview"} {
+				resp, err := noFollowRedirectClient.Get(server.URL + path)
+				require.NoError(t, err)
+				defer resp.Body.Close()
+
+				assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+				assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+			}
+		})
+
+		t.Run("should not count visits before activation", func(t *testing.T) {
+			assert.Zero(t, countVisits(result.Slug))
+		})
+
+		t.Run("should resolve after activation", func(t *testing.T) {
+			_, err := dbConn.Exec("UPDATE shortlinks SET activate_at = ? WHERE slug = ?;",
+				time.Now().UTC().Add(-time.Minute).Format("2006-01-02 15:04:05"), result.Slug)
+			require.NoError(t, err)
+
+			resp, err := noFollowRedirectClient.Get(server.URL + "/" + result.Slug)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, http.StatusMovedPermanently, resp.StatusCode)
+			assert.Equal(t, "https://example.com/webinar", resp.Header.Get("Location"))
+			assert.Equal(t, 1, countVisits(result.Slug))
+		})
+	})
+
+	// ------------------------
+	//      custom slug
+	// ------------------------
+
+	t.Run("encrypted kind", func(t *testing.T) {
+		blob := "v1." + base64.RawURLEncoding.EncodeToString(bytes.Repeat([]byte{7}, 64)) // IV and ciphertext opaque to server
+
+		t.Run("should store blob without checking content", func(t *testing.T) {
+			result := storeShortlink(entities.Shortlink{Kind: "encrypted", Content: blob})
+
+			assert.Equal(t, "encrypted", result.Kind)
+			assert.Equal(t, blob, result.Content)
+		})
+
+		t.Run("should return blob to API clients", func(t *testing.T) {
+			result := storeShortlink(entities.Shortlink{Kind: "encrypted", Content: blob})
+
+			resp, err := http.Get(server.URL + "/" + result.Slug)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, "text/plain; charset=utf-8", resp.Header.Get("Content-Type"))
+			assert.Equal(t, "nosniff", resp.Header.Get("X-Content-Type-Options"))
+			assert.Equal(t, blob, string(body))
+		})
+
+		t.Run("should render viewer page for browsers", func(t *testing.T) {
+			result := storeShortlink(entities.Shortlink{Kind: "encrypted", Content: blob})
+
+			for _, path := range []string{"/" + result.Slug, "/" + result.Slug + "/view"} {
+				req, err := http.NewRequest("GET", server.URL+path, nil)
+				require.NoError(t, err)
+				req.Header.Set("Accept", "text/html")
+
+				resp, err := http.DefaultClient.Do(req)
+				require.NoError(t, err)
+				defer resp.Body.Close()
+
+				body, err := io.ReadAll(resp.Body)
+				require.NoError(t, err)
+
+				assert.Equal(t, http.StatusOK, resp.StatusCode)
+				assert.Equal(t, "text/html; charset=utf-8", resp.Header.Get("Content-Type"))
+				assert.Contains(t, string(body), `data-blob="`+blob+`"`)
+				assert.Contains(t, string(body), "/static/js/encrypted.js")
+				assert.NotContains(t, string(body), "https://") // no third-party scripts next to the key
+				assert.Contains(t, resp.Header.Get("Content-Security-Policy"), "script-src 'self'")
+			}
+		})
+
+		t.Run("should return blob on unlock if protected", func(t *testing.T) {
+			result := storeShortlink(entities.Shortlink{Kind: "encrypted", Content: blob, Password: "securepass123"})
+
+			resp, err := http.Post(server.URL+"/"+result.Slug+"/unlock", "application/json", strings.NewReader(`{"password":"securepass123"}`))
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			var response struct {
+				Data struct {
+					Kind string `json:"kind"`
+					Blob string `json:"blob"`
+				} `json:"data"`
+			}
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, "encrypted", response.Data.Kind)
+			assert.Equal(t, blob, response.Data.Blob)
+		})
+
+		t.Run("should reject invalid blobs", func(t *testing.T) {
+			for _, content := range []string{
+				`{"nodes":[]}`, // plaintext workflow
+				"v2." + base64.RawURLEncoding.EncodeToString(bytes.Repeat([]byte{7}, 64)),
+				"v1.not base64!",
+				"v1." + base64.RawURLEncoding.EncodeToString(bytes.Repeat([]byte{7}, 16)), // shorter than IV and tag
+				"v1." + strings.Repeat("A", 4*1024*1024),
+			} {
+				body, err := json.Marshal(entities.Shortlink{Kind: "encrypted", Content: content})
+				require.NoError(t, err)
+
+				resp, err := http.Post(server.URL+"/shortlink", "application/json", bytes.NewBuffer(body))
+				require.NoError(t, err)
+				defer resp.Body.Close()
+
+				assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+				assert.Equal(t, errors.ToCode[errors.ErrEncryptedBlobInvalid], toErrorResponse(resp.Body).Error.Code)
+			}
+		})
+
+		t.Run("should reject URL-only options", func(t *testing.T) {
+			body := `{"kind":"encrypted","content":"` + blob + `","variants":[{"name":"a","destination":"https://example.com/a","weight":1},{"name":"b","destination":"https://example.com/b","weight":1}]}`
+
+			resp, err := http.Post(server.URL+"/shortlink", "application/json", strings.NewReader(body))
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+			assert.Equal(t, errors.ToCode[errors.ErrVariantsInvalid], toErrorResponse(resp.Body).Error.Code)
+		})
+	})
+
+	t.Run("stats", func(t *testing.T) {
+		type VisitCount struct {
+			Value string `json:"value"`
+			Count int    `json:"count"`
+		}
+
+		type VisitStats struct {
+			Total int    `json:"total"`
+			From  string `json:"from"`
+			To    string `json:"to"`
+			Daily []struct {
+				Date    string `json:"date"`
+				Count   int    `json:"count"`
+				Uniques int    `json:"uniques"`
+			} `json:"daily"`
+			TopReferers   []VisitCount   `json:"top_referers"`
+			TopUserAgents []VisitCount   `json:"top_user_agents"`
+			AccessPaths   map[string]int `json:"access_paths"`
+			Variants      map[string]int `json:"variants"`
+		}
+
+		getStats := func(slug, token, query string) (*http.Response, VisitStats) {
+			req, err := http.NewRequest("GET", server.URL+"/shortlink/"+slug+"/stats"+query, nil)
+			require.NoError(t, err)
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			var response struct {
+				Data VisitStats `json:"data"`
+			}
+			if resp.StatusCode == http.StatusOK {
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+			}
+
+			return resp, response.Data
+		}
+
+		visit := func(path, referer, userAgent string) {
+			req, err := http.NewRequest("GET", server.URL+path, nil)
+			require.NoError(t, err)
+			req.Header.Set("Referer", referer)
+			req.Header.Set("User-Agent", userAgent)
+			req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte("securepass123"))) // ignored if unprotected
+
+			resp, err := noFollowRedirectClient.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+		}
+
+		chrome := "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/129.0.0.0 Safari/537.36"
+		firefox := "Mozilla/5.0 (X11; Linux x86_64; rv:131.0) Gecko/20100101 Firefox/131.0"
+
+		result := storeShortlink(entities.Shortlink{Kind: "workflow", Content: `{"nodes":[]}`})
+
+		visit("/"+result.Slug, "https://www.google.com/search?q=n8n", chrome)
+		visit("/"+result.Slug, "https://google.com/", chrome)
+		visit("/"+result.Slug+"/view", "", firefox)
+
+		_, err := dbConn.Exec("INSERT INTO visits (slug, ts, referer, user_agent) VALUES (?, '2020-01-15 10:00:00', '', 'curl/8.0');", result.Slug)
+		require.NoError(t, err)
+
+		t.Run("should summarize visits over last 30 days by default", func(t *testing.T) {
+			resp, stats := getStats(result.Slug, result.ManagementToken, "")
+
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, 4, stats.Total) // all time
+			require.Len(t, stats.Daily, 30)
+			assert.Equal(t, time.Now().UTC().Format("2006-01-02"), stats.To)
+			assert.Equal(t, stats.To, stats.Daily[29].Date)
+			assert.Equal(t, 3, stats.Daily[29].Count)
+			assert.Equal(t, 2, stats.Daily[29].Uniques) // same IP, two user agents
+			assert.Equal(t, []VisitCount{{"google.com", 2}}, stats.TopReferers)
+			assert.Equal(t, []VisitCount{{"Chrome", 2}, {"Firefox", 1}}, stats.TopUserAgents)
+			assert.Equal(t, map[string]int{"raw": 2, "view": 1}, stats.AccessPaths)
+		})
+
+		t.Run("should summarize visits over requested range", func(t *testing.T) {
+			resp, stats := getStats(result.Slug, result.ManagementToken, "?from=2020-01-01&to=2020-01-31")
+
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Len(t, stats.Daily, 31)
+			assert.Equal(t, "2020-01-15", stats.Daily[14].Date)
+			assert.Equal(t, 1, stats.Daily[14].Count)
+			assert.Zero(t, stats.Daily[14].Uniques) // predating visitor hashes
+			assert.Empty(t, stats.TopReferers)
+			assert.Equal(t, []VisitCount{{"curl", 1}}, stats.TopUserAgents)
+			assert.Equal(t, map[string]int{"unknown": 1}, stats.AccessPaths) // predating access paths
+		})
+
+		t.Run("should count protected visits", func(t *testing.T) {
+			protected := storeShortlink(entities.Shortlink{Kind: "url", Content: "https://example.com", Password: "securepass123"})
+			visit("/"+protected.Slug, "", chrome)
+
+			_, stats := getStats(protected.Slug, protected.ManagementToken, "")
+			assert.Equal(t, map[string]int{"protected": 1}, stats.AccessPaths)
+		})
+
+		t.Run("should count visits by variant", func(t *testing.T) {
+			split := storeShortlink(entities.Shortlink{
+				Kind:    "url",
+				Content: "https://example.com",
+				Variants: entities.Variants{
+					{Name: "a", Destination: "https://example.com/a", Weight: 1},
+					{Name: "b", Destination: "https://example.com/b", Weight: 1},
+				},
+			})
+
+			for range 10 {
+				visit("/"+split.Slug+"/preview", "", chrome)
+			}
+
+			_, stats := getStats(split.Slug, split.ManagementToken, "")
+			assert.Equal(t, 10, stats.Variants["a"]+stats.Variants["b"])
+
+			_, unsplit := getStats(result.Slug, result.ManagementToken, "")
+			assert.Empty(t, unsplit.Variants)
+		})
+
+		t.Run("should classify user agent of visit", func(t *testing.T) {
+			iphone := "Mozilla/5.0 (iPhone; CPU iPhone OS 18_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/18.0 Mobile/15E148 Safari/604.1"
+			visit("/"+result.Slug, "", iphone)
+
+			var visit entities.Visit
+			err := dbConn.Get(&visit, "SELECT * FROM visits WHERE slug = ? ORDER BY id DESC LIMIT 1;", result.Slug)
+			require.NoError(t, err)
+
+			assert.Equal(t, "Safari", visit.Browser)
+			assert.Equal(t, "iOS", visit.OS)
+			assert.Equal(t, "mobile", visit.Device)
+			assert.False(t, visit.IsBot)
+		})
+
+		t.Run("should match generic bot markers on word boundaries", func(t *testing.T) {
+			cubot := "Mozilla/5.0 (Linux; Android 10; CUBOT X30 Build/QP1A.190711.020) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36"
+			ahrefs := "Mozilla/5.0 (compatible; AhrefsBot/7.0; +http://ahrefs.com/robot/)"
+
+			assert.False(t, useragent.Parse(cubot).IsBot)
+			assert.Equal(t, "Chrome", useragent.Parse(cubot).Browser)
+			assert.True(t, useragent.Parse(ahrefs).IsBot)
+			assert.True(t, useragent.Parse("my-link-checker bot").IsBot)
+			assert.True(t, useragent.Parse("Mozilla/5.0 (compatible; Yahoo! Slurp)").IsBot)
+		})
+
+		t.Run("should exclude bots if requested", func(t *testing.T) {
+			bots := []string{
+				"Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)",
+				"Mozilla/5.0 (compatible; Discordbot/2.0; +https://discordapp.com)",
+				"Twitterbot/1.0",
+				"Mozilla/5.0+(compatible; UptimeRobot/2.0; http://www.uptimerobot.com/)",
+			}
+			for _, bot := range bots {
+				visit("/"+result.Slug, "", bot)
+			}
+
+			var flagged int
+			err := dbConn.Get(&flagged, "SELECT COUNT(*) FROM visits WHERE slug = ? AND is_bot = 1;", result.Slug)
+			require.NoError(t, err)
+			assert.Equal(t, len(bots), flagged)
+
+			_, all := getStats(result.Slug, result.ManagementToken, "")
+			_, humans := getStats(result.Slug, result.ManagementToken, "?exclude_bots=true")
+
+			assert.Equal(t, all.Total-len(bots), humans.Total)
+			assert.Contains(t, all.TopUserAgents, VisitCount{"Bot", len(bots)})
+			assert.NotContains(t, humans.TopUserAgents, VisitCount{"Bot", len(bots)})
+		})
+
+		t.Run("should reject invalid range", func(t *testing.T) {
+			for _, query := range []string{"?from=yesterday", "?from=2020-02-01&to=2020-01-01", "?from=2020-01-01&to=2021-01-01"} {
+				resp, _ := getStats(result.Slug, result.ManagementToken, query)
+				assert.Equal(t, http.StatusBadRequest, resp.StatusCode, query)
+			}
+		})
+
+		t.Run("should require management token", func(t *testing.T) {
+			resp, _ := getStats(result.Slug, "wrong-token", "")
+			assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		})
+	})
+
+	t.Run("visit queue", func(t *testing.T) {
+		result := storeShortlink(entities.Shortlink{Kind: "url", Content: "https://example.com/queued"})
+
+		countVisits := func() int {
+			var count int
+			err := dbConn.Get(&count, "SELECT COUNT(*) FROM visits WHERE slug = ?;", result.Slug)
+			require.NoError(t, err)
+			return count
+		}
+
+		newQueuedVisitService := func(size, batchSize int) *services.VisitService {
+			vs := &services.VisitService{DB: dbConn, Logger: &logger, BatchSize: batchSize, FlushInterval: time.Hour}
+			vs.InitQueue(size)
+			return vs
+		}
+
+		visit := entities.Visit{Slug: result.Slug, AccessPath: entities.AccessPathRaw}
+
+		t.Run("should write visits once batch is full", func(t *testing.T) {
+			vs := newQueuedVisitService(10, 2)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go vs.Start(ctx)
+
+			before := countVisits()
+			require.NoError(t, vs.SaveVisit(visit, "url", "127.0.0.1"))
+			require.NoError(t, vs.SaveVisit(visit, "url", "127.0.0.1"))
+
+			assert.Eventually(t, func() bool { return countVisits() == before+2 }, time.Second, 10*time.Millisecond)
+		})
+
+		t.Run("should drain queue on cancellation", func(t *testing.T) {
+			vs := newQueuedVisitService(10, 100)
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				vs.Start(ctx)
+				close(done)
+			}()
+
+			before := countVisits()
+			for range 3 {
+				require.NoError(t, vs.SaveVisit(visit, "url", "127.0.0.1"))
+			}
+
+			cancel()
+			<-done
+
+			assert.Equal(t, before+3, countVisits())
+			assert.Zero(t, vs.QueueDepth())
+		})
+
+		t.Run("should stamp queued visits when they happen", func(t *testing.T) {
+			vs := newQueuedVisitService(10, 100)
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				vs.Start(ctx)
+				close(done)
+			}()
+
+			stamped := storeShortlink(entities.Shortlink{Kind: "url", Content: "https://example.com/stamped"})
+			before := time.Now().UTC().Truncate(time.Second)
+			require.NoError(t, vs.SaveVisit(entities.Visit{Slug: stamped.Slug, AccessPath: entities.AccessPathRaw}, "url", "127.0.0.1"))
+			after := time.Now().UTC().Truncate(time.Second)
+
+			time.Sleep(1100 * time.Millisecond) // written a second later
+			cancel()
+			<-done
+
+			var ts entities.CustomTime
+			require.NoError(t, dbConn.Get(&ts, "SELECT ts FROM visits WHERE slug = ?;", stamped.Slug))
+			assert.False(t, ts.Time.Before(before))
+			assert.False(t, ts.Time.After(after))
+		})
+
+		t.Run("should drop visits if queue is full", func(t *testing.T) {
+			vs := newQueuedVisitService(1, 100) // not started
+			require.NoError(t, vs.SaveVisit(visit, "url", "127.0.0.1"))
+			assert.ErrorIs(t, vs.SaveVisit(visit, "url", "127.0.0.1"), errors.ErrVisitQueueFull)
+
+			originalVisitService := api.VisitService
+			api.VisitService = vs
+			defer func() { api.VisitService = originalVisitService }()
+
+			resp, err := noFollowRedirectClient.Get(server.URL + "/" + result.Slug)
+			require.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, http.StatusMovedPermanently, resp.StatusCode) // unaffected by dropped visit
+
+			resp, err = http.Get(server.URL + "/metrics")
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			assert.Contains(t, string(body), "visit_queue_depth 1")
+			assert.Contains(t, string(body), "visits_dropped_total 1")
+		})
+	})
+
+	t.Run("visitor hashing", func(t *testing.T) {
+		result := storeShortlink(entities.Shortlink{Kind: "url", Content: "https://example.com/hashed"})
+
+		t.Run("should store visitor hash instead of IP", func(t *testing.T) {
+			resp, err := noFollowRedirectClient.Get(server.URL + "/" + result.Slug)
+			require.NoError(t, err)
+			resp.Body.Close()
+
+			var visit entities.Visit
+			err = dbConn.Get(&visit, "SELECT * FROM visits WHERE slug = ? ORDER BY id DESC LIMIT 1;", result.Slug)
+			require.NoError(t, err)
+
+			expected, err := visitorHasher.Hash("127.0.0.1", visit.UserAgent)
+			require.NoError(t, err)
+			assert.Equal(t, expected, visit.VisitorHash)
+		})
+
+		t.Run("should share daily salt across restarts and delete previous salts", func(t *testing.T) {
+			_, err := dbConn.Exec("INSERT INTO visitor_salts (day, salt) VALUES ('2020-01-01', randomblob(32));")
+			require.NoError(t, err)
+
+			restarted := &services.VisitorHasher{DB: dbConn}
+			hash, err := restarted.Hash("127.0.0.1", "TestUserAgent/1.0")
+			require.NoError(t, err)
+
+			expected, err := visitorHasher.Hash("127.0.0.1", "TestUserAgent/1.0")
+			require.NoError(t, err)
+			assert.Equal(t, expected, hash)
+
+			var days []string
+			require.NoError(t, dbConn.Select(&days, "SELECT day FROM visitor_salts;"))
+			assert.Equal(t, []string{time.Now().UTC().Format("2006-01-02")}, days)
+		})
+
+		t.Run("should hash creator IP if enabled", func(t *testing.T) {
+			api.ShortlinkService.IPHasher = visitorHasher
+			defer func() { api.ShortlinkService.IPHasher = nil }()
+
+			hashed := storeShortlink(entities.Shortlink{Kind: "url", Content: "https://example.com/hashed-creator"})
+
+			var creatorIP string
+			err := dbConn.Get(&creatorIP, "SELECT creator_ip FROM shortlinks WHERE slug = ?;", hashed.Slug)
+			require.NoError(t, err)
+
+			expected, err := visitorHasher.Hash("127.0.0.1")
+			require.NoError(t, err)
+			assert.Equal(t, expected, creatorIP)
+		})
+	})
+
+	t.Run("visit retention", func(t *testing.T) {
+		result := storeShortlink(entities.Shortlink{Kind: "url", Content: "https://example.com/retained"})
+
+		vs := &services.VisitService{DB: dbConn, Logger: &logger, RetentionDays: 90, PruneChunkSize: 2}
+
+		dayA := time.Now().UTC().AddDate(0, 0, -200).Format("2006-01-02")
+		dayB := time.Now().UTC().AddDate(0, 0, -199).Format("2006-01-02")
+
+		insertVisit := func(ts, referer, visitorHash string, isBot bool) {
+			query := "INSERT INTO visits (slug, ts, referer, user_agent, visitor_hash, is_bot) VALUES (?, ?, ?, '', ?, ?);"
+			_, err := dbConn.Exec(query, result.Slug, ts, referer, visitorHash, isBot)
+			require.NoError(t, err)
+		}
+
+		insertVisit(dayA+" 08:00:00", "https://www.google.com/", "a", false)
+		insertVisit(dayA+" 09:00:00", "https://google.com/search", "a", false)
+		insertVisit(dayA+" 10:00:00", "https://example.org/", "b", false)
+		insertVisit(dayA+" 11:00:00", "", "c", true)
+		insertVisit(dayB+" 12:00:00", "", "d", false)
+		insertVisit(time.Now().UTC().Format("2006-01-02 15:04:05"), "", "e", false)
+
+		_, err := dbConn.Exec("UPDATE visits SET variant = CASE visitor_hash WHEN 'b' THEN 'b' ELSE 'a' END WHERE slug = ? AND ts < ?;", result.Slug, dayB)
+		require.NoError(t, err)
+
+		countRaw := func() int {
+			var count int
+			require.NoError(t, dbConn.Get(&count, "SELECT COUNT(*) FROM visits WHERE slug = ?;", result.Slug))
+			return count
+		}
+
+		getStats := func(query string) map[string]any {
+			req, err := http.NewRequest("GET", server.URL+"/shortlink/"+result.Slug+"/stats"+query, nil)
+			require.NoError(t, err)
+			req.Header.Set("Authorization", "Bearer "+result.ManagementToken)
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+
+			var response struct {
+				Data map[string]any `json:"data"`
+			}
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+
+			return response.Data
+		}
+
+		before := getStats("?from=" + dayA + "&to=" + dayB)
+
+		t.Run("should roll up and prune visits past retention", func(t *testing.T) {
+			pruned, err := vs.PruneVisits(context.Background())
+			require.NoError(t, err)
+			assert.GreaterOrEqual(t, pruned, 5) // incl. old visits of other slugs
+			assert.Equal(t, 1, countRaw())
+
+			var rollups []entities.VisitRollup
+			require.NoError(t, dbConn.Select(&rollups, "SELECT * FROM visit_daily_rollups WHERE slug = ? ORDER BY day;", result.Slug))
+			assert.Equal(t, []entities.VisitRollup{
+				{Slug: result.Slug, Day: dayA, Count: 4, BotCount: 1, Uniques: 3, TopReferer: "google.com", TopRefererCount: 2},
+				{Slug: result.Slug, Day: dayB, Count: 1, Uniques: 1},
+			}, rollups)
+
+			var variantRollups []entities.VisitVariantRollup
+			require.NoError(t, dbConn.Select(&variantRollups, "SELECT * FROM visit_daily_variant_rollups WHERE slug = ? ORDER BY variant;", result.Slug))
+			assert.Equal(t, []entities.VisitVariantRollup{
+				{Slug: result.Slug, Day: dayA, Variant: "a", Count: 3, BotCount: 1},
+				{Slug: result.Slug, Day: dayA, Variant: "b", Count: 1},
+			}, variantRollups)
+		})
+
+		t.Run("should read stats from raw visits and rollups alike", func(t *testing.T) {
+			after := getStats("?from=" + dayA + "&to=" + dayB)
+
+			assert.Equal(t, before["total"], after["total"])
+			assert.Equal(t, before["daily"], after["daily"])
+			assert.Equal(t, before["variants"], after["variants"])
+			assert.Equal(t, map[string]any{"a": float64(3), "b": float64(1)}, after["variants"])
+			assert.Equal(t, map[string]any{"a": float64(2), "b": float64(1)}, getStats("?from=" + dayA + "&to=" + dayB + "&exclude_bots=true")["variants"])
+			assert.Equal(t, []any{map[string]any{"value": "google.com", "count": float64(2)}}, after["top_referers"]) // only top one kept
+			assert.EqualValues(t, 6, after["total"])
+			assert.EqualValues(t, 5, getStats("?exclude_bots=true")["total"])
+		})
+
+		t.Run("should not count leftovers of interrupted pruning twice", func(t *testing.T) {
+			insertVisit(dayA+" 12:00:00", "", "f", false) // as if not yet deleted
+
+			count, err := vs.CountVisits(result.Slug, false)
+			require.NoError(t, err)
+			assert.Equal(t, 6, count)
+
+			_, err = vs.PruneVisits(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, 1, countRaw())
+
+			count, err = vs.CountVisits(result.Slug, false)
+			require.NoError(t, err)
+			assert.Equal(t, 6, count)
+		})
+	})
+
+	t.Run("exports", func(t *testing.T) {
+		result := storeShortlink(entities.Shortlink{Kind: "url", Content: "https://example.com/exported"})
+
+		for _, userAgent := range []string{"ExportAgent/1.0", "ExportAgent/2.0"} {
+			req, err := http.NewRequest("GET", server.URL+"/"+result.Slug, nil)
+			require.NoError(t, err)
+			req.Header.Set("User-Agent", userAgent)
+
+			resp, err := noFollowRedirectClient.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+		}
+
+		export := func(path, token string) (*http.Response, string) {
+			req, err := http.NewRequest("GET", server.URL+path, nil)
+			require.NoError(t, err)
+			if token != "" {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			return resp, string(body)
+		}
+
+		t.Run("should export visits as CSV", func(t *testing.T) {
+			resp, body := export("/shortlink/"+result.Slug+"/visits.csv", result.ManagementToken)
+
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, "text/csv; charset=utf-8", resp.Header.Get("Content-Type"))
+			assert.Contains(t, resp.Header.Get("Content-Disposition"), result.Slug+"-visits.csv")
+
+			records, err := csv.NewReader(strings.NewReader(body)).ReadAll()
+			require.NoError(t, err)
+			require.Len(t, records, 3)
+			assert.Equal(t, "user_agent", records[0][3])
+			assert.Equal(t, "ExportAgent/1.0", records[1][3])
+			assert.Equal(t, "ExportAgent/2.0", records[2][3])
+			assert.Equal(t, "raw", records[1][5])
+		})
+
+		t.Run("should escape formulas in CSV export only", func(t *testing.T) {
+			formulas := storeShortlink(entities.Shortlink{Kind: "url", Content: "https://example.com/formulas"})
+
+			req, err := http.NewRequest("GET", server.URL+"/"+formulas.Slug, nil)
+			require.NoError(t, err)
+			req.Header.Set("User-Agent", `=HYPERLINK("https://evil.com","click")`)
+			req.Header.Set("Referer", "@SUM(1+1)")
+
+			resp, err := noFollowRedirectClient.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+
+			_, body := export("/shortlink/"+formulas.Slug+"/visits.csv", formulas.ManagementToken)
+
+			records, err := csv.NewReader(strings.NewReader(body)).ReadAll()
+			require.NoError(t, err)
+			require.Len(t, records, 2)
+			assert.Equal(t, "'@SUM(1+1)", records[1][2])
+			assert.Equal(t, `'=HYPERLINK("https://evil.com","click")`, records[1][3])
+
+			_, body = export("/shortlink/"+formulas.Slug+"/visits.ndjson", formulas.ManagementToken)
+
+			var visit entities.Visit
+			require.NoError(t, json.Unmarshal([]byte(strings.TrimSpace(body)), &visit))
+			assert.Equal(t, "@SUM(1+1)", visit.Referer)
+			assert.Equal(t, `=HYPERLINK("https://evil.com","click")`, visit.UserAgent)
+		})
+
+		t.Run("should export visits as NDJSON within range", func(t *testing.T) {
+			today := time.Now().UTC().Format("2006-01-02")
+
+			resp, body := export("/shortlink/"+result.Slug+"/visits.ndjson?from="+today+"&to="+today, result.ManagementToken)
+
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, "application/x-ndjson", resp.Header.Get("Content-Type"))
+
+			lines := strings.Split(strings.TrimSpace(body), "\n")
+			require.Len(t, lines, 2)
+
+			var visit entities.Visit
+			require.NoError(t, json.Unmarshal([]byte(lines[0]), &visit))
+			assert.Equal(t, result.Slug, visit.Slug)
+			assert.Equal(t, "ExportAgent/1.0", visit.UserAgent)
+
+			_, body = export("/shortlink/"+result.Slug+"/visits.ndjson?to=2020-01-01", result.ManagementToken)
+			assert.Empty(t, body)
+		})
+
+		t.Run("should reject invalid export range or token", func(t *testing.T) {
+			resp, _ := export("/shortlink/"+result.Slug+"/visits.csv?from=2020-02-01&to=2020-01-01", result.ManagementToken)
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+			resp, _ = export("/shortlink/"+result.Slug+"/visits.csv", "wrong-token")
+			assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		})
+
+		t.Run("should export all shortlinks to admin", func(t *testing.T) {
+			resp, _ := export("/admin/shortlinks.ndjson", "")
+			assert.Equal(t, http.StatusNotFound, resp.StatusCode) // no admin token configured
+
+			cfg.Admin.Token = "test-admin-token"
+			defer func() { cfg.Admin.Token = "" }()
+
+			resp, body := export("/admin/shortlinks.ndjson", "wrong-token")
+			assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+			assert.Equal(t, errors.ToCode[errors.ErrAdminTokenInvalid], toErrorResponse(io.NopCloser(strings.NewReader(body))).Error.Code)
+
+			resp, body = export("/admin/shortlinks.ndjson", "test-admin-token")
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.NotContains(t, body, "https://example.com/exported")
+
+			var exported map[string]any
+			for _, line := range strings.Split(strings.TrimSpace(body), "\n") {
+				var shortlink map[string]any
+				require.NoError(t, json.Unmarshal([]byte(line), &shortlink))
+				if shortlink["slug"] == result.Slug {
+					exported = shortlink
+				}
+			}
+			require.NotNil(t, exported)
+			assert.Equal(t, "url", exported["kind"])
+			assert.EqualValues(t, 2, exported["visits"])
+			assert.Equal(t, true, exported["is_encrypted_at_rest"])
+			assert.NotContains(t, exported, "content")
+
+			resp, body = export("/admin/shortlinks.csv", "test-admin-token")
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.True(t, strings.HasPrefix(body, "slug,kind,created_at,"))
+		})
+
+		t.Run("should escape formulas in creator IP in CSV export", func(t *testing.T) {
+			cfg.Admin.Token = "test-admin-token"
+			defer func() { cfg.Admin.Token = "" }()
+
+			req, err := http.NewRequest("POST", server.URL+"/shortlink", strings.NewReader(`{"content":"https://example.com/creator-ip"}`))
+			require.NoError(t, err)
+			req.Header.Set("X-Real-Ip", "=1+1")
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+			var created struct {
+				Data entities.Shortlink `json:"data"`
+			}
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+
+			_, body := export("/admin/shortlinks.csv", "test-admin-token")
+
+			records, err := csv.NewReader(strings.NewReader(body)).ReadAll()
+			require.NoError(t, err)
+
+			var creatorIP string
+			for _, record := range records {
+				if record[0] == created.Data.Slug {
+					creatorIP = record[5]
+				}
+			}
+			assert.Equal(t, "'=1+1", creatorIP)
+		})
+	})
+
+	t.Run("live events", func(t *testing.T) {
+		result := storeShortlink(entities.Shortlink{Kind: "url", Content: "https://example.com/live"})
+
+		t.Run("should stream visits as they happen", func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			req, err := http.NewRequestWithContext(ctx, "GET", server.URL+"/shortlink/"+result.Slug+"/events", nil)
+			require.NoError(t, err)
+			req.Header.Set("Authorization", "Bearer "+result.ManagementToken)
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+			reader := bufio.NewReader(resp.Body)
+			line, err := reader.ReadString('\n')
+			require.NoError(t, err)
+			assert.Equal(t, ": connected\n", line) // subscribed
+
+			visitReq, err := http.NewRequest("GET", server.URL+"/"+result.Slug, nil)
+			require.NoError(t, err)
+			visitReq.Header.Set("User-Agent", "Slackbot-LinkExpanding 1.0")
+			visitResp, err := noFollowRedirectClient.Do(visitReq)
+			require.NoError(t, err)
+			visitResp.Body.Close()
+
+			for line != "event: visit\n" {
+				line, err = reader.ReadString('\n')
+				require.NoError(t, err)
+			}
+
+			line, err = reader.ReadString('\n')
+			require.NoError(t, err)
+
+			data, ok := strings.CutPrefix(strings.TrimSpace(line), "data: ")
+			require.True(t, ok)
+
+			var event struct {
+				UserAgent  string `json:"user_agent"`
+				Browser    string `json:"browser"`
+				IsBot      bool   `json:"is_bot"`
+				AccessPath string `json:"access_path"`
+				TS         string `json:"ts"`
+			}
+			require.NoError(t, json.Unmarshal([]byte(data), &event))
+			assert.Equal(t, "Slackbot-LinkExpanding 1.0", event.UserAgent)
+			assert.Equal(t, "Slackbot", event.Browser)
+			assert.True(t, event.IsBot)
+			assert.Equal(t, "raw", event.AccessPath)
+			assert.NotEmpty(t, event.TS)
+		})
+
+		t.Run("should require management token", func(t *testing.T) {
+			req, err := http.NewRequest("GET", server.URL+"/shortlink/"+result.Slug+"/events", nil)
+			require.NoError(t, err)
+			req.Header.Set("Authorization", "Bearer wrong-token")
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		})
+
+		t.Run("should drop subscriber that falls behind", func(t *testing.T) {
+			broker := services.NewVisitBroker(1)
+			visits, unsubscribe := broker.Subscribe(result.Slug)
+			defer unsubscribe()
+
+			broker.Publish(entities.Visit{Slug: result.Slug, UserAgent: "first"})
+			broker.Publish(entities.Visit{Slug: result.Slug, UserAgent: "second"}) // buffer full
+
+			visit, ok := <-visits
+			assert.True(t, ok)
+			assert.Equal(t, "first", visit.UserAgent)
+
+			_, ok = <-visits
+			assert.False(t, ok)
+		})
+
+		t.Run("should end streams on close", func(t *testing.T) {
+			broker := services.NewVisitBroker(1)
+			visits, _ := broker.Subscribe(result.Slug)
+
+			broker.Close()
+			broker.Publish(entities.Visit{Slug: result.Slug}) // no-op
+
+			_, ok := <-visits
+			assert.False(t, ok)
+
+			visits, _ = broker.Subscribe(result.Slug)
+			_, ok = <-visits
+			assert.False(t, ok)
+		})
+	})
+
+	t.Run("domain metrics", func(t *testing.T) {
+		scrape := func() string {
+			resp, err := http.Get(server.URL + "/metrics")
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			return string(body)
+		}
+
+		metricValue := func(body, series string) float64 {
+			for _, line := range strings.Split(body, "\n") {
+				if value, ok := strings.CutPrefix(line, series+" "); ok {
+					var parsed float64
+					_, err := fmt.Sscanf(value, "%g", &parsed)
+					require.NoError(t, err)
+					return parsed
+				}
+			}
+
+			return 0 // series not yet exposed
+		}
+
+		t.Run("should count created shortlinks by kind and protection", func(t *testing.T) {
+			series := `shortlinks_created_total{kind="url",protected="true"}`
+			before := metricValue(scrape(), series)
+
+			storeShortlink(entities.Shortlink{Kind: "url", Content: "https://example.com", Password: "securepass123"})
+
+			assert.Equal(t, before+1, metricValue(scrape(), series))
+		})
+
+		t.Run("should count resolves by outcome", func(t *testing.T) {
+			okSeries := `shortlink_resolves_total{kind="url",outcome="ok"}`
+			notFoundSeries := `shortlink_resolves_total{kind="unknown",outcome="not_found"}`
+			unauthorizedSeries := `shortlink_resolves_total{kind="url",outcome="unauthorized"}`
+
+			body := scrape()
+			okBefore := metricValue(body, okSeries)
+			notFoundBefore := metricValue(body, notFoundSeries)
+			unauthorizedBefore := metricValue(body, unauthorizedSeries)
+
+			result := storeShortlink(entities.Shortlink{Kind: "url", Content: "https://example.com"})
+			protected := storeShortlink(entities.Shortlink{Kind: "url", Content: "https://example.com", Password: "securepass123"})
+
+			for _, path := range []string{"/" + result.Slug, "/missing-metrics-slug", "/" + protected.Slug} {
+				resp, err := noFollowRedirectClient.Get(server.URL + path)
+				require.NoError(t, err)
+				resp.Body.Close()
+			}
+
+			body = scrape()
+			assert.Equal(t, okBefore+1, metricValue(body, okSeries))
+			assert.Equal(t, notFoundBefore+1, metricValue(body, notFoundSeries))
+			assert.Equal(t, unauthorizedBefore+1, metricValue(body, unauthorizedSeries))
+		})
+
+		t.Run("should count expired and not yet active resolves as expired", func(t *testing.T) {
+			expiredSeries := `shortlink_resolves_total{kind="url",outcome="expired"}`
+			expiredBefore := metricValue(scrape(), expiredSeries)
+
+			expired := storeShortlink(entities.Shortlink{
+				Kind:      "url",
+				Content:   "https://example.com/expired",
+				ExpiresAt: &entities.CustomTime{Time: time.Now().Add(-time.Hour)},
+			})
+			scheduled := storeShortlink(entities.Shortlink{
+				Kind:       "url",
+				Content:    "https://example.com/scheduled",
+				ActivateAt: &entities.CustomTime{Time: time.Now().Add(time.Hour)},
+			})
+
+			for _, slug := range []string{expired.Slug, scheduled.Slug} {
+				resp, err := noFollowRedirectClient.Get(server.URL + "/" + slug)
+				require.NoError(t, err)
+				resp.Body.Close()
+				assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+			}
+
+			assert.Equal(t, expiredBefore+2, metricValue(scrape(), expiredSeries))
+		})
+
+		t.Run("should count blocked content by rule", func(t *testing.T) {
+			hostSeries := `content_blocked_total{rule="deny_hosts:cpanel.site"}`
+			schemeSeries := `content_blocked_total{rule="schemes"}`
+
+			body := scrape()
+			hostBefore := metricValue(body, hostSeries)
+			schemeBefore := metricValue(body, schemeSeries)
+
+			for _, content := range []string{"https://login.cpanel.site/x", "ftp://example.com/file"} {
+				body, err := json.Marshal(entities.Shortlink{Content: content})
+				require.NoError(t, err)
+
+				resp, err := http.Post(server.URL+"/shortlink", "application/json", bytes.NewBuffer(body))
+				require.NoError(t, err)
+				resp.Body.Close()
+
+				assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+			}
+
+			body = scrape()
+			assert.Equal(t, hostBefore+1, metricValue(body, hostSeries))
+			assert.Equal(t, schemeBefore+1, metricValue(body, schemeSeries))
+			assert.NotContains(t, body, `rule="schemes:ftp"`)
+		})
+
+		t.Run("should report stored shortlinks by kind on refresh", func(t *testing.T) {
+			storeShortlink(entities.Shortlink{Kind: "url", Content: "https://example.com"})
+			require.NoError(t, api.RefreshGauges())
+
+			var urlCount, workflowCount int
+			require.NoError(t, dbConn.Get(&urlCount, "SELECT COUNT(*) FROM shortlinks WHERE kind = 'url';"))
+			require.NoError(t, dbConn.Get(&workflowCount, "SELECT COUNT(*) FROM shortlinks WHERE kind = 'workflow';"))
+
+			body := scrape()
+			assert.Equal(t, float64(urlCount), metricValue(body, `shortlinks_stored{kind="url"}`))
+			assert.Equal(t, float64(workflowCount), metricValue(body, `shortlinks_stored{kind="workflow"}`))
+			assert.Contains(t, body, `shortlinks_stored{kind="encrypted"}`)
+		})
+	})
+
+	t.Run("custom slug", func(t *testing.T) {
+		t.Run("should create custom-slug shortlink and redirect on retrieval", func(t *testing.T) {
+			candidate := entities.Shortlink{
+				Slug:    "my-custom-slug",
+				Kind:    "url",
+				Content: "https://example.org",
+			}
+
+			result := storeShortlink(candidate)
+
+			assert.Equal(t, candidate.Slug, result.Slug)
+			assert.Equal(t, candidate.Kind, result.Kind)
+			assert.Equal(t, candidate.Content, result.Content)
+
+			resp, err := noFollowRedirectClient.Get(server.URL + "/" + result.Slug)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, http.StatusMovedPermanently, resp.StatusCode)
+			assert.Equal(t, candidate.Content, resp.Header.Get("Location"))
+		})
+	})
+
+	// ------------------------
+	//   creation validation
+	// ------------------------
+
+	t.Run("creation payload validation", func(t *testing.T) {
+		t.Run("should reject on invalid creation payload", func(t *testing.T) {
+			testCases := []struct {
+				name               string
+				shortlink          entities.Shortlink
+				expectedStatusCode int
+				expectedErrorCode  string
+			}{
+				{
+					name:               "Content as empty string",
+					shortlink:          entities.Shortlink{}, // content defaults to empty string
+					expectedStatusCode: http.StatusBadRequest,
+					expectedErrorCode:  errors.ToCode[errors.ErrContentMalformed],
+				},
+				{
+					name:              "Content as neither URL nor JSON",
+					shortlink:         entities.Shortlink{Content: "not-a-url"},
+					expectedErrorCode: errors.ToCode[errors.ErrContentMalformed],
+				},
+				{
+					name:              "Password too short",
+					shortlink:         entities.Shortlink{Content: "https://example.com", Password: "1234567"},
+					expectedErrorCode: errors.ToCode[errors.ErrPasswordTooShort],
+				},
+			}
+
+			for _, tc := range testCases {
+				t.Run(tc.name, func(t *testing.T) {
+					body, err := json.Marshal(tc.shortlink)
+					require.NoError(t, err)
+
+					resp, err := http.Post(server.URL+"/shortlink", "application/json", bytes.NewBuffer(body))
+					require.NoError(t, err)
+					defer resp.Body.Close()
+
+					errorResponse := toErrorResponse(resp.Body)
+
+					assert.Equal(t, errorResponse.Error.Code, tc.expectedErrorCode)
+				})
+			}
+		})
+
+		t.Run("should reject on malformed JSON", func(t *testing.T) {
+			resp, err := http.Post(server.URL+"/shortlink", "application/json", strings.NewReader("create shortlink"))
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+			errorResponse := toErrorResponse(resp.Body)
+			assert.Equal(t, errors.ToCode[errors.ErrContentMalformed], errorResponse.Error.Code)
+		})
+
+		t.Run("should reject on duplicate custom slug in payload", func(t *testing.T) {
+			candidate := entities.Shortlink{
+				Slug:    "some-custom-slug",
+				Kind:    "url",
+				Content: "https://example.com",
+			}
+
+			result := storeShortlink(candidate)
+
+			duplicate := entities.Shortlink{
+				Slug:    result.Slug, // already exists
+				Content: "https://other-example.com",
+			}
+
+			body, err := json.Marshal(duplicate)
+			require.NoError(t, err)
+			resp, err := http.Post(server.URL+"/shortlink", "application/json", bytes.NewBuffer(body))
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+			errorResponse := toErrorResponse(resp.Body)
+			assert.Equal(t, errors.ToCode[errors.ErrSlugTaken], errorResponse.Error.Code)
+
+			// verify that original shortlink is still intact
+			resp, err = noFollowRedirectClient.Get(server.URL + "/" + candidate.Slug)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, http.StatusMovedPermanently, resp.StatusCode)
+			assert.Equal(t, "https://example.com", resp.Header.Get("Location"))
+		})
+
+		t.Run("should reject on invalid custom slug", func(t *testing.T) {
+			testCases := []struct {
+				errorCode string
+				slug      string
+			}{
+				{errors.ToCode[errors.ErrSlugTooShort], "abc"},
+				{errors.ToCode[errors.ErrSlugTooLong], strings.Repeat("a", 513)},
+				{errors.ToCode[errors.ErrSlugMisformatted], "abc+def"},
+				{errors.ToCode[errors.ErrSlugReserved], "health"},
+				{errors.ToCode[errors.ErrSlugReserved], "admin"},
+			}
+
+			for _, tc := range testCases {
+				shortlink := entities.Shortlink{
+					Slug:    tc.slug,
+					Content: "https://example.com",
+				}
+
+				body, _ := json.Marshal(shortlink)
+				resp, err := http.Post(server.URL+"/shortlink", "application/json", bytes.NewBuffer(body))
+				require.NoError(t, err)
+				defer resp.Body.Close()
+
+				assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+				errorResponse := toErrorResponse(resp.Body)
+
+				assert.Equal(t, errorResponse.Error.Code, tc.errorCode)
+			}
+		})
+
+		t.Run("should reject payload >= 5 MB", func(t *testing.T) {
+			tooBig := strings.Repeat("a", 5*1024*1024) // 5 MB
+			tooBigCandidate := entities.Shortlink{
+				Content: "https://example.com?" + tooBig,
+			}
+
+			body, err := json.Marshal(tooBigCandidate)
+			require.NoError(t, err)
+
+			resp, err := http.Post(server.URL+"/shortlink", "application/json", bytes.NewBuffer(body))
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+			errorResponse := toErrorResponse(resp.Body)
+			assert.Equal(t, errorResponse.Error.Code, errors.ToCode[errors.ErrPayloadTooLarge])
+
+			// retry with smaller payload
+
+			rightSized := strings.Repeat("a", 4*1024*1024) // 4 MB
+			rightSizedCandidate := entities.Shortlink{
+				Kind:    "url",
+				Content: "https://example.com?" + rightSized,
+			}
+
+			result := storeShortlink(rightSizedCandidate)
+
+			assert.NotEmpty(t, result.Slug)
+			assert.Equal(t, rightSizedCandidate.Kind, result.Kind)
+			assert.Equal(t, rightSizedCandidate.Content, result.Content)
+		})
+	})
+
+	t.Run("rate limiting", func(t *testing.T) {
+		t.Run("should enforce rate limiting", func(t *testing.T) {
+			// enable rate limiting only for this test
+			originalConfig := *api.Config
+			api.Config.RateLimiter.Enabled = true
+			api.Config.RateLimiter.RPS = 2
+			api.Config.RateLimiter.Burst = 2
+			defer func() {
+				api.Config = &originalConfig
+			}()
+
+			shortlink := entities.Shortlink{
+				Content: "https://example.com",
+			}
+			body, err := json.Marshal(shortlink)
+			require.NoError(t, err)
+
+			// util to make a request
+			makeRequest := func() (*http.Response, error) {
+				return http.Post(server.URL+"/shortlink", "application/json", bytes.NewBuffer(body))
+			}
+
+			// make requests up to the limit
+			for i := 0; i < api.Config.RateLimiter.Burst; i++ {
+				resp, err := makeRequest()
+				require.NoError(t, err)
+				defer resp.Body.Close()
+				assert.Equal(t, http.StatusCreated, resp.StatusCode)
+			}
+
+			resp, err := makeRequest() // should be rate limited
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+
+			errorResponse := toErrorResponse(resp.Body)
+
+			assert.Equal(t, "You have exceeded the rate limit. Please wait and retry later.", errorResponse.Error.Message)
+
+			time.Sleep(time.Second) // wait for rate limit to reset
+
+			resp, err = makeRequest() // should succeed
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		})
+	})
+
+	t.Run("password protection", func(t *testing.T) {
+		t.Run("should store password-protected shortlink", func(t *testing.T) {
+			candidate := entities.Shortlink{
+				Kind:     "url",
+				Content:  "https://example.com",
+				Password: "securepass123",
+			}
+
+			result := storeShortlink(candidate)
+
+			var storedPassword string
+			err = dbConn.Get(&storedPassword, "SELECT password FROM shortlinks WHERE slug = ?", result.Slug)
+			require.NoError(t, err)
+			assert.NotEmpty(t, storedPassword)
+			assert.NotContains(t, storedPassword, "securepass123") // has been hashed
+
+			assert.NotEmpty(t, result.Slug)
+			assert.Equal(t, candidate.Kind, result.Kind)
+			assert.Equal(t, candidate.Content, result.Content)
+			assert.Empty(t, result.Password) // not returned in response
+		})
+
+		t.Run("should show challenge for password-protected shortlink", func(t *testing.T) {
+			result := storeShortlink(entities.Shortlink{
+				Kind:     "url",
+				Content:  "https://example.com/protected",
+				Password: "securepass123",
+			})
+
+			resp, err := http.Get(server.URL + "/" + result.Slug)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assertChallengeShown(resp)
 		})
 
-		t.Run("should return original URL if correct password", func(t *testing.T) {
-			plainPassword := "securepass123"
-			candidate := entities.Shortlink{
-				Kind:     "url",
-				Content:  "https://example.com/protected",
-				Password: plainPassword,
+		t.Run("should return original URL if correct password", func(t *testing.T) {
+			plainPassword := "securepass123"
+			candidate := entities.Shortlink{
+				Kind:     "url",
+				Content:  "https://example.com/protected",
+				Password: plainPassword,
+			}
+			result := storeShortlink(candidate)
+
+			req, err := http.NewRequest("GET", server.URL+"/"+result.Slug, nil)
+			require.NoError(t, err)
+
+			auth := base64.StdEncoding.EncodeToString([]byte(plainPassword))
+			req.Header.Add("Authorization", "Basic "+auth)
+
+			resp, err := noFollowRedirectClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			bodyBytes, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			bodyString := string(bodyBytes)
+
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+			assert.Equal(t, "{\"url\":\"https://example.com/protected\"}\n", bodyString)
+		})
+
+		t.Run("should deny access with incorrect password", func(t *testing.T) {
+			candidate := entities.Shortlink{
+				Kind:     "url",
+				Content:  "https://example.com/protected",
+				Password: "securepass123",
+			}
+			result := storeShortlink(candidate)
+
+			req, err := http.NewRequest("GET", server.URL+"/"+result.Slug, nil)
+			require.NoError(t, err)
+
+			auth := base64.StdEncoding.EncodeToString([]byte("wrongpass"))
+			req.Header.Add("Authorization", "Basic "+auth)
+
+			resp, err := noFollowRedirectClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		})
+
+		t.Run("should show challenge on missing Authorization header", func(t *testing.T) {
+			candidate := entities.Shortlink{
+				Kind:     "url",
+				Content:  "https://example.com/protected",
+				Password: "securepass123",
+			}
+			result := storeShortlink(candidate)
+
+			req, err := http.NewRequest("GET", server.URL+"/"+result.Slug, nil)
+			require.NoError(t, err)
+
+			resp, err := noFollowRedirectClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assertChallengeShown(resp)
+		})
+
+		t.Run("should reject on malformed Authorization header", func(t *testing.T) {
+			candidate := entities.Shortlink{
+				Kind:     "url",
+				Content:  "https://example.com/protected",
+				Password: "securepass123",
+			}
+			result := storeShortlink(candidate)
+
+			req, err := http.NewRequest("GET", server.URL+"/"+result.Slug, nil)
+			require.NoError(t, err)
+
+			auth := base64.StdEncoding.EncodeToString([]byte("wrongpass"))
+			req.Header.Add("Authorization", "B_a_s_i_c "+auth)
+
+			resp, err := noFollowRedirectClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		})
+
+		t.Run("should reject on missing password in Authorization header", func(t *testing.T) {
+			candidate := entities.Shortlink{
+				Kind:     "url",
+				Content:  "https://example.com/protected",
+				Password: "securepass123",
+			}
+			result := storeShortlink(candidate)
+
+			req, err := http.NewRequest("GET", server.URL+"/"+result.Slug, nil)
+			require.NoError(t, err)
+
+			auth := base64.StdEncoding.EncodeToString([]byte(""))
+			req.Header.Add("Authorization", "B_a_s_i_c "+auth)
+
+			resp, err := noFollowRedirectClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		})
+
+		t.Run("password hashing", func(t *testing.T) {
+			getStoredHash := func(slug string) string {
+				var hash string
+				err := dbConn.Get(&hash, "SELECT password FROM shortlinks WHERE slug = ?", slug)
+				require.NoError(t, err)
+				return hash
+			}
+
+			unlock := func(slug, password string) int {
+				body, err := json.Marshal(map[string]string{"password": password})
+				require.NoError(t, err)
+
+				resp, err := http.Post(server.URL+"/"+slug+"/unlock", "application/json", bytes.NewBuffer(body))
+				require.NoError(t, err)
+				resp.Body.Close()
+
+				return resp.StatusCode
+			}
+
+			t.Run("should hash password with argon2id in PHC format", func(t *testing.T) {
+				result := storeShortlink(entities.Shortlink{Kind: "url", Content: "https://example.com", Password: "securepass123"})
+
+				assert.True(t, strings.HasPrefix(getStoredHash(result.Slug), "$argon2id$v=19$m=1024,t=1,p=1$"))
+			})
+
+			t.Run("should distinguish passwords beyond 72 bytes", func(t *testing.T) {
+				prefix := strings.Repeat("a", 72)
+				result := storeShortlink(entities.Shortlink{Kind: "url", Content: "https://example.com", Password: prefix + "-first"})
+
+				assert.Equal(t, http.StatusUnauthorized, unlock(result.Slug, prefix+"-second"))
+				assert.Equal(t, http.StatusOK, unlock(result.Slug, prefix+"-first"))
+			})
+
+			t.Run("should verify legacy bcrypt hash and upgrade it", func(t *testing.T) {
+				result := storeShortlink(entities.Shortlink{Kind: "url", Content: "https://example.com", Password: "securepass123"})
+
+				legacyHash, err := bcrypt.GenerateFromPassword([]byte("securepass123"), bcrypt.MinCost)
+				require.NoError(t, err)
+				_, err = dbConn.Exec("UPDATE shortlinks SET password = ? WHERE slug = ?", string(legacyHash), result.Slug)
+				require.NoError(t, err)
+
+				assert.Equal(t, http.StatusUnauthorized, unlock(result.Slug, "wrongpass"))
+				assert.Equal(t, string(legacyHash), getStoredHash(result.Slug)) // not upgraded on failure
+
+				assert.Equal(t, http.StatusOK, unlock(result.Slug, "securepass123"))
+				assert.True(t, strings.HasPrefix(getStoredHash(result.Slug), "$argon2id$"))
+
+				assert.Equal(t, http.StatusOK, unlock(result.Slug, "securepass123"))
+			})
+
+			t.Run("should upgrade hash on outdated params", func(t *testing.T) {
+				result := storeShortlink(entities.Shortlink{Kind: "url", Content: "https://example.com", Password: "securepass123"})
+				originalHash := getStoredHash(result.Slug)
+
+				originalHasher := api.ShortlinkService.Hasher
+				api.ShortlinkService.Hasher = &services.Argon2idHasher{
+					Params: services.Argon2idParams{MemoryKiB: 2048, Iterations: 2, Parallelism: 1},
+				}
+				defer func() {
+					api.ShortlinkService.Hasher = originalHasher
+				}()
+
+				assert.Equal(t, http.StatusOK, unlock(result.Slug, "securepass123"))
+
+				upgradedHash := getStoredHash(result.Slug)
+				assert.NotEqual(t, originalHash, upgradedHash)
+				assert.True(t, strings.HasPrefix(upgradedHash, "$argon2id$v=19$m=2048,t=2,p=1$"))
+
+				assert.Equal(t, http.StatusOK, unlock(result.Slug, "securepass123"))
+				assert.Equal(t, upgradedHash, getStoredHash(result.Slug)) // not upgraded again
+			})
+		})
+
+		t.Run("encryption at rest", func(t *testing.T) {
+			plainPassword := "securepass123"
+			workflow := `{"nodes":[{"name":"Secret node"}]}`
+
+			getWithPassword := func(slug string) *http.Response {
+				req, err := http.NewRequest("GET", server.URL+"/"+slug, nil)
+				require.NoError(t, err)
+				req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(plainPassword)))
+
+				resp, err := noFollowRedirectClient.Do(req)
+				require.NoError(t, err)
+
+				return resp
+			}
+
+			t.Run("should store content encrypted", func(t *testing.T) {
+				result := storeShortlink(entities.Shortlink{Kind: "workflow", Content: workflow, Password: plainPassword})
+				assert.Equal(t, workflow, result.Content) // returned as submitted
+
+				var stored struct {
+					Content     string         `db:"content"`
+					ContentKeys sql.NullString `db:"content_keys"`
+				}
+				err := dbConn.Get(&stored, "SELECT content, content_keys FROM shortlinks WHERE slug = ?", result.Slug)
+				require.NoError(t, err)
+
+				assert.NotContains(t, stored.Content, "Secret node")
+				assert.True(t, stored.ContentKeys.Valid)
+				assert.NotContains(t, stored.ContentKeys.String, plainPassword)
+			})
+
+			t.Run("should decrypt content on correct password", func(t *testing.T) {
+				result := storeShortlink(entities.Shortlink{Kind: "workflow", Content: workflow, Password: plainPassword})
+
+				resp := getWithPassword(result.Slug)
+				defer resp.Body.Close()
+
+				body, err := io.ReadAll(resp.Body)
+				require.NoError(t, err)
+
+				assert.Equal(t, http.StatusOK, resp.StatusCode)
+				assert.JSONEq(t, workflow, string(body))
+			})
+
+			t.Run("should not derive content keys for unprotected shortlink", func(t *testing.T) {
+				result := storeShortlink(entities.Shortlink{Kind: "workflow", Content: workflow})
+
+				var storedContentKeys sql.NullString
+				err := dbConn.Get(&storedContentKeys, "SELECT content_keys FROM shortlinks WHERE slug = ?", result.Slug)
+				require.NoError(t, err)
+
+				assert.False(t, storedContentKeys.Valid)
+			})
+
+			t.Run("should resolve protected shortlink predating encryption", func(t *testing.T) {
+				hash, err := api.ShortlinkService.HashPassword(plainPassword)
+				require.NoError(t, err)
+
+				_, err = dbConn.Exec(
+					"INSERT INTO shortlinks (slug, kind, content, creator_ip, password) VALUES (?, ?, ?, ?, ?);",
+					"legacy-protected", "url", "https://example.com/legacy", "127.0.0.1", hash,
+				)
+				require.NoError(t, err)
+
+				resp := getWithPassword("legacy-protected")
+				defer resp.Body.Close()
+
+				body, err := io.ReadAll(resp.Body)
+				require.NoError(t, err)
+
+				assert.Equal(t, http.StatusOK, resp.StatusCode)
+				assert.Equal(t, "{\"url\":\"https://example.com/legacy\"}\n", string(body))
+			})
+
+			t.Run("should store destinations of protected URL shortlink encrypted", func(t *testing.T) {
+				result := storeShortlink(entities.Shortlink{
+					Kind:         "url",
+					Content:      "https://example.com/secret-default",
+					Password:     plainPassword,
+					RoutingRules: entities.RoutingRules{{Languages: []string{"de"}, Destination: "https://example.com/secret-de"}},
+					Variants: entities.Variants{
+						{Name: "a", Destination: "https://example.com/secret-a", Weight: 1},
+						{Name: "b", Destination: "https://example.com/secret-a", Weight: 1},
+					},
+				})
+				assert.Len(t, result.RoutingRules, 1) // returned as submitted
+				assert.Len(t, result.Variants, 2)
+
+				var stored struct {
+					RedirectChain sql.NullString `db:"redirect_chain"`
+					RoutingRules  sql.NullString `db:"routing_rules"`
+					Variants      sql.NullString `db:"variants"`
+					SealedRouting sql.NullString `db:"sealed_routing"`
+				}
+				err := dbConn.Get(&stored, "SELECT redirect_chain, routing_rules, variants, sealed_routing FROM shortlinks WHERE slug = ?", result.Slug)
+				require.NoError(t, err)
+
+				assert.False(t, stored.RedirectChain.Valid)
+				assert.False(t, stored.RoutingRules.Valid)
+				assert.False(t, stored.Variants.Valid)
+				assert.True(t, stored.SealedRouting.Valid)
+				assert.NotContains(t, stored.SealedRouting.String, "secret")
+
+				unlock := func(acceptLanguage string) string {
+					req, err := http.NewRequest("POST", server.URL+"/"+result.Slug+"/unlock", strings.NewReader(`{"password":"securepass123"}`))
+					require.NoError(t, err)
+					req.Header.Set("Content-Type", "application/json")
+					req.Header.Set("Accept-Language", acceptLanguage)
+
+					resp, err := http.DefaultClient.Do(req)
+					require.NoError(t, err)
+					defer resp.Body.Close()
+					require.Equal(t, http.StatusOK, resp.StatusCode)
+
+					var response struct {
+						Data struct {
+							URL string `json:"url"`
+						} `json:"data"`
+					}
+					require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+
+					return response.Data.URL
+				}
+
+				assert.Equal(t, "https://example.com/secret-de", unlock("de"))
+				assert.Equal(t, "https://example.com/secret-a", unlock("en"))
+
+				// replacing rules keeps them and the variants encrypted
+
+				req, err := http.NewRequest("PUT", server.URL+"/shortlink/"+result.Slug+"/rules",
+					strings.NewReader(`{"rules":[{"languages":["fr"],"destination":"https://example.com/secret-fr"}]}`))
+				require.NoError(t, err)
+				req.Header.Set("Authorization", "Bearer "+result.ManagementToken)
+
+				resp, err := http.DefaultClient.Do(req)
+				require.NoError(t, err)
+				defer resp.Body.Close()
+				assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+				err = dbConn.Get(&stored, "SELECT redirect_chain, routing_rules, variants, sealed_routing FROM shortlinks WHERE slug = ?", result.Slug)
+				require.NoError(t, err)
+
+				assert.False(t, stored.RoutingRules.Valid)
+				assert.NotContains(t, stored.SealedRouting.String, "secret")
+
+				assert.Equal(t, "https://example.com/secret-fr", unlock("fr"))
+				assert.Equal(t, "https://example.com/secret-a", unlock("de"))
+
+				req, err = http.NewRequest("GET", server.URL+"/shortlink/"+result.Slug+"/rules", nil)
+				require.NoError(t, err)
+				req.Header.Set("Authorization", "Bearer "+result.ManagementToken)
+
+				resp, err = http.DefaultClient.Do(req)
+				require.NoError(t, err)
+				defer resp.Body.Close()
+
+				var response struct {
+					Data struct {
+						Rules entities.RoutingRules `json:"rules"`
+					} `json:"data"`
+				}
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+				require.Len(t, response.Data.Rules, 1)
+				assert.Equal(t, "https://example.com/secret-fr", response.Data.Rules[0].Destination)
+			})
+		})
+
+		t.Run("JSON unlock", func(t *testing.T) {
+			plainPassword := "securepass123"
+
+			postUnlock := func(slug string, body string) *http.Response {
+				resp, err := http.Post(server.URL+"/"+slug+"/unlock", "application/json", strings.NewReader(body))
+				require.NoError(t, err)
+				return resp
+			}
+
+			type UnlockedShortlink struct {
+				Slug     string          `json:"slug"`
+				Kind     string          `json:"kind"`
+				URL      string          `json:"url"`
+				Workflow json.RawMessage `json:"workflow"`
+			}
+
+			decodeUnlocked := func(resp *http.Response) UnlockedShortlink {
+				var response struct {
+					Data UnlockedShortlink `json:"data"`
+				}
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+				return response.Data
+			}
+
+			urlResult := storeShortlink(entities.Shortlink{Kind: "url", Content: "https://example.com/protected", Password: plainPassword})
+			workflowResult := storeShortlink(entities.Shortlink{Kind: "workflow", Content: `{"nodes":[]}`, Password: plainPassword})
+
+			t.Run("should return URL on correct password", func(t *testing.T) {
+				resp := postUnlock(urlResult.Slug, `{"password":"securepass123"}`)
+				defer resp.Body.Close()
+
+				assert.Equal(t, http.StatusOK, resp.StatusCode)
+				assert.Len(t, resp.Cookies(), 1) // unlock session
+
+				unlocked := decodeUnlocked(resp)
+				assert.Equal(t, urlResult.Slug, unlocked.Slug)
+				assert.Equal(t, "url", unlocked.Kind)
+				assert.Equal(t, "https://example.com/protected", unlocked.URL)
+				assert.Empty(t, unlocked.Workflow)
+			})
+
+			t.Run("should return workflow on correct password", func(t *testing.T) {
+				resp := postUnlock(workflowResult.Slug, `{"password":"securepass123"}`)
+				defer resp.Body.Close()
+
+				assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+				unlocked := decodeUnlocked(resp)
+				assert.Equal(t, "workflow", unlocked.Kind)
+				assert.JSONEq(t, `{"nodes":[]}`, string(unlocked.Workflow))
+				assert.Empty(t, unlocked.URL)
+			})
+
+			t.Run("should return unprotected shortlink without password", func(t *testing.T) {
+				result := storeShortlink(entities.Shortlink{Kind: "url", Content: "https://example.com/public"})
+
+				resp := postUnlock(result.Slug, "")
+				defer resp.Body.Close()
+
+				assert.Equal(t, http.StatusOK, resp.StatusCode)
+				assert.Equal(t, "https://example.com/public", decodeUnlocked(resp).URL)
+			})
+
+			t.Run("should reject with error codes", func(t *testing.T) {
+				testCases := []struct {
+					name       string
+					slug       string
+					body       string
+					statusCode int
+					errorCode  string
+				}{
+					{"wrong password", urlResult.Slug, `{"password":"wrongpass"}`, http.StatusUnauthorized, errors.ToCode[errors.ErrPasswordInvalid]},
+					{"missing password", urlResult.Slug, `{}`, http.StatusBadRequest, errors.ToCode[errors.ErrPasswordMissing]},
+					{"malformed payload", urlResult.Slug, `securepass123`, http.StatusBadRequest, errors.ToCode[errors.ErrPayloadMalformed]},
+					{"unknown slug", "nonexistent-slug", `{"password":"securepass123"}`, http.StatusNotFound, errors.ToCode[errors.ErrShortlinkNotFound]},
+				}
+
+				for _, tc := range testCases {
+					t.Run(tc.name, func(t *testing.T) {
+						resp := postUnlock(tc.slug, tc.body)
+						defer resp.Body.Close()
+
+						assert.Equal(t, tc.statusCode, resp.StatusCode)
+						assert.Equal(t, tc.errorCode, toErrorResponse(resp.Body).Error.Code)
+					})
+				}
+			})
+		})
+
+		t.Run("unlock sessions", func(t *testing.T) {
+			plainPassword := "securepass123"
+
+			unlock := func(slug string) *http.Cookie {
+				req, err := http.NewRequest("GET", server.URL+"/"+slug, nil)
+				require.NoError(t, err)
+				req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(plainPassword)))
+
+				resp, err := noFollowRedirectClient.Do(req)
+				require.NoError(t, err)
+				defer resp.Body.Close()
+
+				require.Equal(t, http.StatusOK, resp.StatusCode)
+				cookies := resp.Cookies()
+				require.Len(t, cookies, 1)
+
+				return cookies[0]
+			}
+
+			visitWithCookie := func(path string, cookie *http.Cookie) *http.Response {
+				req, err := http.NewRequest("GET", server.URL+path, nil)
+				require.NoError(t, err)
+				req.AddCookie(cookie)
+
+				resp, err := noFollowRedirectClient.Do(req)
+				require.NoError(t, err)
+
+				return resp
 			}
-			result := storeShortlink(candidate)
 
-			req, err := http.NewRequest("GET", server.URL+"/"+result.Slug, nil)
-			require.NoError(t, err)
+			urlResult := storeShortlink(entities.Shortlink{Kind: "url", Content: "https://example.com/protected", Password: plainPassword})
+			workflowResult := storeShortlink(entities.Shortlink{Kind: "workflow", Content: `{"nodes":[]}`, Password: plainPassword})
 
-			auth := base64.StdEncoding.EncodeToString([]byte(plainPassword))
-			req.Header.Add("Authorization", "Basic "+auth)
+			t.Run("should issue HttpOnly cookie scoped to slug on correct password", func(t *testing.T) {
+				cookie := unlock(urlResult.Slug)
 
-			resp, err := noFollowRedirectClient.Do(req)
-			require.NoError(t, err)
-			defer resp.Body.Close()
+				assert.Equal(t, "/"+urlResult.Slug, cookie.Path)
+				assert.True(t, cookie.HttpOnly)
+				assert.Equal(t, int(time.Hour.Seconds()), cookie.MaxAge)
+			})
 
-			bodyBytes, err := io.ReadAll(resp.Body)
-			require.NoError(t, err)
-			bodyString := string(bodyBytes)
+			t.Run("should resolve protected shortlink with cookie", func(t *testing.T) {
+				resp := visitWithCookie("/"+urlResult.Slug, unlock(urlResult.Slug))
+				defer resp.Body.Close()
 
-			assert.Equal(t, http.StatusOK, resp.StatusCode)
-			assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
-			assert.Equal(t, "{\"url\":\"https://example.com/protected\"}\n", bodyString)
+				assert.Equal(t, http.StatusMovedPermanently, resp.StatusCode)
+				assert.Equal(t, "https://example.com/protected", resp.Header.Get("Location"))
+				assert.Equal(t, "no-store", resp.Header.Get("Cache-Control"))
+
+				resp = visitWithCookie("/"+workflowResult.Slug+"/view", unlock(workflowResult.Slug))
+				defer resp.Body.Close()
+
+				assert.Equal(t, http.StatusOK, resp.StatusCode)
+				assert.Equal(t, "text/html; charset=utf-8", resp.Header.Get("Content-Type"))
+			})
+
+			t.Run("should record one visit for session-only unlock and reload", func(t *testing.T) {
+				countVisits := func() int {
+					var count int
+					require.NoError(t, dbConn.Get(&count, "SELECT COUNT(*) FROM visits WHERE slug = ?;", workflowResult.Slug))
+					return count
+				}
+				before := countVisits()
+
+				resp, err := http.Post(
+					server.URL+"/"+workflowResult.Slug+"/unlock",
+					"application/json",
+					strings.NewReader(`{"password":"`+plainPassword+`","session_only":true}`),
+				)
+				require.NoError(t, err)
+				defer resp.Body.Close()
+
+				assert.Equal(t, http.StatusOK, resp.StatusCode)
+				var response struct {
+					Data map[string]any `json:"data"`
+				}
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+				assert.Equal(t, map[string]any{"slug": workflowResult.Slug, "kind": "workflow"}, response.Data)
+				assert.Equal(t, before, countVisits())
+
+				cookies := resp.Cookies()
+				require.Len(t, cookies, 1)
+
+				resp = visitWithCookie("/"+workflowResult.Slug, cookies[0]) // as reloaded by the challenge page
+				defer resp.Body.Close()
+
+				assert.Equal(t, http.StatusOK, resp.StatusCode)
+				assert.Equal(t, before+1, countVisits())
+			})
+
+			t.Run("should show challenge with cookie of other slug", func(t *testing.T) {
+				resp := visitWithCookie("/"+workflowResult.Slug, unlock(urlResult.Slug))
+				defer resp.Body.Close()
+
+				assertChallengeShown(resp)
+			})
+
+			t.Run("should show challenge with tampered cookie", func(t *testing.T) {
+				cookie := unlock(urlResult.Slug)
+				expiry, signature, _ := strings.Cut(cookie.Value, ".")
+				cookie.Value = expiry + "0." + signature // extend expiry
+
+				resp := visitWithCookie("/"+urlResult.Slug, cookie)
+				defer resp.Body.Close()
+
+				assertChallengeShown(resp)
+			})
+
+			t.Run("should show challenge after password change", func(t *testing.T) {
+				cookie := unlock(urlResult.Slug)
+
+				newHash, err := api.ShortlinkService.HashPassword("newsecurepass456")
+				require.NoError(t, err)
+				_, err = dbConn.Exec("UPDATE shortlinks SET password = ? WHERE slug = ?;", newHash, urlResult.Slug)
+				require.NoError(t, err)
+
+				resp := visitWithCookie("/"+urlResult.Slug, cookie)
+				defer resp.Body.Close()
+
+				assertChallengeShown(resp)
+			})
 		})
 
-		t.Run("should deny access with incorrect password", func(t *testing.T) {
-			candidate := entities.Shortlink{
-				Kind:     "url",
-				Content:  "https://example.com/protected",
-				Password: "securepass123",
+		t.Run("share tokens", func(t *testing.T) {
+			sendShareTokens := func(method, slug, managementToken, body string) *http.Response {
+				req, err := http.NewRequest(method, server.URL+"/shortlink/"+slug+"/share-tokens", strings.NewReader(body))
+				require.NoError(t, err)
+				req.Header.Set("Authorization", "Bearer "+managementToken)
+
+				resp, err := http.DefaultClient.Do(req)
+				require.NoError(t, err)
+
+				return resp
 			}
-			result := storeShortlink(candidate)
 
-			req, err := http.NewRequest("GET", server.URL+"/"+result.Slug, nil)
-			require.NoError(t, err)
+			mint := func(slug, managementToken, body string) (string, string, time.Time) {
+				resp := sendShareTokens("POST", slug, managementToken, body)
+				defer resp.Body.Close()
 
-			auth := base64.StdEncoding.EncodeToString([]byte("wrongpass"))
-			req.Header.Add("Authorization", "Basic "+auth)
+				require.Equal(t, http.StatusCreated, resp.StatusCode)
 
-			resp, err := noFollowRedirectClient.Do(req)
-			require.NoError(t, err)
-			defer resp.Body.Close()
+				var response struct {
+					Data struct {
+						Token     string    `json:"token"`
+						ExpiresAt time.Time `json:"expires_at"`
+						Path      string    `json:"path"`
+					} `json:"data"`
+				}
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
 
-			assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+				return response.Data.Token, response.Data.Path, response.Data.ExpiresAt
+			}
+
+			visitPath := func(path string) *http.Response {
+				resp, err := noFollowRedirectClient.Get(server.URL + path)
+				require.NoError(t, err)
+				return resp
+			}
+
+			result := storeShortlink(entities.Shortlink{
+				Kind:         "url",
+				Content:      "https://example.com/protected",
+				Password:     "securepass123",
+				ForwardQuery: true,
+			})
+
+			t.Run("should grant access with share token", func(t *testing.T) {
+				_, path, expiresAt := mint(result.Slug, result.ManagementToken, "")
+				assert.WithinDuration(t, time.Now().Add(24*time.Hour), expiresAt, time.Minute)
+
+				resp := visitPath(path + "&ref=customer")
+				defer resp.Body.Close()
+
+				assert.Equal(t, http.StatusMovedPermanently, resp.StatusCode)
+				assert.Equal(t, "https://example.com/protected?ref=customer", resp.Header.Get("Location")) // token not forwarded
+			})
+
+			t.Run("should show challenge with invalid or expired share token", func(t *testing.T) {
+				token, _, _ := mint(result.Slug, result.ManagementToken, `{"ttl_seconds":1}`)
+				_, signature, _ := strings.Cut(token, ".")
+
+				for _, badToken := range []string{"not-a-token", fmt.Sprint(time.Now().Add(time.Hour).Unix()) + "." + signature} {
+					resp := visitPath("/" + result.Slug + "?t=" + badToken)
+					defer resp.Body.Close()
+					assertChallengeShown(resp)
+				}
+
+				time.Sleep(time.Second)
+
+				resp := visitPath("/" + result.Slug + "?t=" + token)
+				defer resp.Body.Close()
+				assertChallengeShown(resp)
+			})
+
+			t.Run("should revoke share tokens by rotating secret", func(t *testing.T) {
+				_, path, _ := mint(result.Slug, result.ManagementToken, "")
+
+				resp := sendShareTokens("DELETE", result.Slug, result.ManagementToken, "")
+				defer resp.Body.Close()
+				assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+				resp = visitPath(path)
+				defer resp.Body.Close()
+				assertChallengeShown(resp)
+
+				_, path, _ = mint(result.Slug, result.ManagementToken, "")
+				resp = visitPath(path)
+				defer resp.Body.Close()
+				assert.Equal(t, http.StatusMovedPermanently, resp.StatusCode)
+			})
+
+			t.Run("should reject minting share tokens", func(t *testing.T) {
+				unprotected := storeShortlink(entities.Shortlink{Kind: "url", Content: "https://example.com"})
+
+				testCases := []struct {
+					name            string
+					slug            string
+					managementToken string
+					body            string
+					statusCode      int
+					errorCode       string
+				}{
+					{"wrong management token", result.Slug, "wrong-token", "", http.StatusUnauthorized, errors.ToCode[errors.ErrManagementTokenInvalid]},
+					{"unprotected shortlink", unprotected.Slug, unprotected.ManagementToken, "", http.StatusBadRequest, errors.ToCode[errors.ErrShortlinkNotProtected]},
+					{"TTL too long", result.Slug, result.ManagementToken, `{"ttl_seconds":2592001}`, http.StatusBadRequest, errors.ToCode[errors.ErrShareTokenTTLInvalid]},
+					{"negative TTL", result.Slug, result.ManagementToken, `{"ttl_seconds":-1}`, http.StatusBadRequest, errors.ToCode[errors.ErrShareTokenTTLInvalid]},
+				}
+
+				for _, tc := range testCases {
+					t.Run(tc.name, func(t *testing.T) {
+						resp := sendShareTokens("POST", tc.slug, tc.managementToken, tc.body)
+						defer resp.Body.Close()
+
+						assert.Equal(t, tc.statusCode, resp.StatusCode)
+						assert.Equal(t, tc.errorCode, toErrorResponse(resp.Body).Error.Code)
+					})
+				}
+			})
 		})
 
-		t.Run("should show challenge on missing Authorization header", func(t *testing.T) {
-			candidate := entities.Shortlink{
-				Kind:     "url",
-				Content:  "https://example.com/protected",
-				Password: "securepass123",
+		t.Run("brute-force protection", func(t *testing.T) {
+			// enable unlock guard only for these tests
+			originalConfig := *api.Config
+			api.Config.UnlockGuard.Enabled = true
+			api.Config.UnlockGuard.FreeAttempts = 2
+			api.Config.UnlockGuard.BaseDelay = time.Minute
+			api.Config.UnlockGuard.LockoutThreshold = 4
+			api.Config.UnlockGuard.LockoutDuration = time.Hour
+			defer func() {
+				api.Config = &originalConfig
+			}()
+
+			// each test as a different client, identified by X-Real-IP
+			attemptUnlock := func(slug, password, clientIP string) *http.Response {
+				req, err := http.NewRequest("GET", server.URL+"/"+slug, nil)
+				require.NoError(t, err)
+				req.Header.Set("X-Real-IP", clientIP)
+				req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(password)))
+
+				resp, err := noFollowRedirectClient.Do(req)
+				require.NoError(t, err)
+				resp.Body.Close()
+
+				return resp
 			}
-			result := storeShortlink(candidate)
 
-			req, err := http.NewRequest("GET", server.URL+"/"+result.Slug, nil)
+			storeProtected := func() string {
+				return storeShortlink(entities.Shortlink{
+					Kind:     "url",
+					Content:  "https://example.com/protected",
+					Password: "securepass123",
+				}).Slug
+			}
+
+			t.Run("should back off after free attempts, per client", func(t *testing.T) {
+				slug := storeProtected()
+				otherSlug := storeProtected()
+
+				for range api.Config.UnlockGuard.FreeAttempts + 1 {
+					assert.Equal(t, http.StatusUnauthorized, attemptUnlock(slug, "wrongpass", "203.0.113.1").StatusCode)
+				}
+
+				resp := attemptUnlock(slug, "securepass123", "203.0.113.1") // blocked even if correct
+				assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+				assert.Equal(t, "60", resp.Header.Get("Retry-After"))
+
+				resp = attemptUnlock(otherSlug, "securepass123", "203.0.113.1")
+				assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+			})
+
+			t.Run("should back off after free attempts, per slug", func(t *testing.T) {
+				slug := storeProtected()
+
+				for i := range api.Config.UnlockGuard.FreeAttempts + 1 {
+					clientIP := fmt.Sprintf("198.51.100.%d", i+1)
+					assert.Equal(t, http.StatusUnauthorized, attemptUnlock(slug, "wrongpass", clientIP).StatusCode)
+				}
+
+				resp := attemptUnlock(slug, "securepass123", "198.51.100.99")
+				assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+			})
+
+			t.Run("should throttle parallel attempts from many clients", func(t *testing.T) {
+				slug := storeProtected()
+
+				var wg sync.WaitGroup
+				statusCodes := make([]int, 20)
+				for i := range statusCodes {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						statusCodes[i] = attemptUnlock(slug, "wrongpass", fmt.Sprintf("198.18.0.%d", i+1)).StatusCode
+					}()
+				}
+				wg.Wait()
+
+				unauthorized := 0
+				for _, statusCode := range statusCodes {
+					if statusCode == http.StatusUnauthorized {
+						unauthorized++
+					} else {
+						assert.Equal(t, http.StatusTooManyRequests, statusCode)
+					}
+				}
+				assert.Equal(t, api.Config.UnlockGuard.FreeAttempts+1, unauthorized) // last one sets the backoff
+			})
+
+			t.Run("should not count correct password against slug", func(t *testing.T) {
+				slug := storeProtected()
+
+				for i := range api.Config.UnlockGuard.FreeAttempts {
+					assert.Equal(t, http.StatusUnauthorized, attemptUnlock(slug, "wrongpass", fmt.Sprintf("198.18.1.%d", i+1)).StatusCode)
+				}
+
+				assert.Equal(t, http.StatusOK, attemptUnlock(slug, "securepass123", "198.18.1.50").StatusCode)
+				assert.Equal(t, http.StatusUnauthorized, attemptUnlock(slug, "wrongpass", "198.18.1.51").StatusCode)
+			})
+
+			t.Run("should lock out at threshold", func(t *testing.T) {
+				api.Config.UnlockGuard.BaseDelay = 0 // skip backoff to reach threshold
+
+				slug := storeProtected()
+
+				for range api.Config.UnlockGuard.LockoutThreshold {
+					assert.Equal(t, http.StatusUnauthorized, attemptUnlock(slug, "wrongpass", "192.0.2.1").StatusCode)
+				}
+
+				resp := attemptUnlock(slug, "securepass123", "192.0.2.2")
+				assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+				assert.Equal(t, "3600", resp.Header.Get("Retry-After"))
+			})
+
+			t.Run("should forget client's failed attempts on success", func(t *testing.T) {
+				api.Config.UnlockGuard.BaseDelay = time.Minute
+
+				for range api.Config.UnlockGuard.FreeAttempts {
+					assert.Equal(t, http.StatusUnauthorized, attemptUnlock(storeProtected(), "wrongpass", "192.0.2.10").StatusCode)
+				}
+
+				assert.Equal(t, http.StatusOK, attemptUnlock(storeProtected(), "securepass123", "192.0.2.10").StatusCode)
+
+				for range api.Config.UnlockGuard.FreeAttempts {
+					assert.Equal(t, http.StatusUnauthorized, attemptUnlock(storeProtected(), "wrongpass", "192.0.2.10").StatusCode)
+				}
+			})
+
+			t.Run("should sweep stale attempts until cancelled", func(t *testing.T) {
+				ctx, cancel := context.WithCancel(context.Background())
+				done := make(chan struct{})
+
+				go func() {
+					api.StartUnlockGuardSweep(ctx, time.Millisecond)
+					close(done)
+				}()
+
+				time.Sleep(10 * time.Millisecond)
+				cancel()
+
+				select {
+				case <-done:
+				case <-time.After(time.Second):
+					t.Fatal("sweep did not stop on cancel")
+				}
+			})
+
+			t.Run("should count failed attempts in metrics", func(t *testing.T) {
+				resp, err := http.Get(server.URL + "/metrics")
+				require.NoError(t, err)
+				defer resp.Body.Close()
+
+				body, err := io.ReadAll(resp.Body)
+				require.NoError(t, err)
+				assert.Regexp(t, `password_failures_total [1-9]`, string(body))
+				assert.Regexp(t, `shortlink_resolves_total\{kind="url",outcome="throttled"\} [1-9]`, string(body))
+			})
+		})
+	})
+
+	t.Run("server-key encryption", func(t *testing.T) {
+		workflow := `{"nodes":[{"name":"Secret node"}]}`
+
+		getStoredKeyID := func(slug string) string {
+			var keyID sql.NullString
+			err := dbConn.Get(&keyID, "SELECT encryption_key_id FROM shortlinks WHERE slug = ?", slug)
 			require.NoError(t, err)
+			return keyID.String
+		}
 
-			resp, err := noFollowRedirectClient.Do(req)
+		getWorkflow := func(slug string) string {
+			resp, err := http.Get(server.URL + "/" + slug)
 			require.NoError(t, err)
 			defer resp.Body.Close()
 
-			assertChallengeShown(resp)
-		})
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
 
-		t.Run("should reject on malformed Authorization header", func(t *testing.T) {
-			candidate := entities.Shortlink{
-				Kind:     "url",
-				Content:  "https://example.com/protected",
-				Password: "securepass123",
-			}
-			result := storeShortlink(candidate)
+			return string(body)
+		}
 
-			req, err := http.NewRequest("GET", server.URL+"/"+result.Slug, nil)
+		useKeyring := func(keys string) {
+			rotated, err := services.LoadKeyring(keys, "")
 			require.NoError(t, err)
+			api.ShortlinkService.Keyring = rotated
+			api.LinkHealthService.Keyring = rotated
+		}
 
-			auth := base64.StdEncoding.EncodeToString([]byte("wrongpass"))
-			req.Header.Add("Authorization", "B_a_s_i_c "+auth)
+		defer func() {
+			api.ShortlinkService.Keyring = keyring
+			api.LinkHealthService.Keyring = keyring
+		}()
 
-			resp, err := noFollowRedirectClient.Do(req)
+		t.Run("should store content encrypted with active key", func(t *testing.T) {
+			result := storeShortlink(entities.Shortlink{Kind: "workflow", Content: workflow})
+			assert.Equal(t, workflow, result.Content)
+
+			var storedContent string
+			err := dbConn.Get(&storedContent, "SELECT content FROM shortlinks WHERE slug = ?", result.Slug)
 			require.NoError(t, err)
-			defer resp.Body.Close()
 
-			assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+			assert.NotContains(t, storedContent, "Secret node")
+			assert.Equal(t, "test-1", getStoredKeyID(result.Slug))
+			assert.JSONEq(t, workflow, getWorkflow(result.Slug))
 		})
 
-		t.Run("should reject on missing password in Authorization header", func(t *testing.T) {
-			candidate := entities.Shortlink{
-				Kind:     "url",
-				Content:  "https://example.com/protected",
-				Password: "securepass123",
-			}
-			result := storeShortlink(candidate)
+		t.Run("should not decrypt content moved to another slug", func(t *testing.T) {
+			result := storeShortlink(entities.Shortlink{Kind: "workflow", Content: workflow})
 
-			req, err := http.NewRequest("GET", server.URL+"/"+result.Slug, nil)
+			_, err := dbConn.Exec("UPDATE shortlinks SET slug = 'moved-secret' WHERE slug = ?;", result.Slug)
 			require.NoError(t, err)
 
-			auth := base64.StdEncoding.EncodeToString([]byte(""))
-			req.Header.Add("Authorization", "B_a_s_i_c "+auth)
+			_, err = api.ShortlinkService.GetBySlug("moved-secret")
+			assert.Error(t, err)
 
-			resp, err := noFollowRedirectClient.Do(req)
+			_, err = dbConn.Exec("DELETE FROM shortlinks WHERE slug = 'moved-secret';")
 			require.NoError(t, err)
-			defer resp.Body.Close()
+		})
 
-			assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		t.Run("should rotate keys and re-encrypt", func(t *testing.T) {
+			result := storeShortlink(entities.Shortlink{Kind: "workflow", Content: workflow})
+
+			_, err := dbConn.Exec(
+				"INSERT INTO shortlinks (slug, kind, content, creator_ip, password) VALUES ('plaintext-workflow', 'workflow', ?, '127.0.0.1', '');",
+				workflow,
+			)
+			require.NoError(t, err)
+
+			testKey1, testKey2, _ := strings.Cut(testKeys, ",")
+			useKeyring(testKey2 + "," + testKey1) // new active key, old one kept for decryption
+
+			assert.JSONEq(t, workflow, getWorkflow(result.Slug))
+
+			count, err := api.ShortlinkService.ReencryptAll(context.Background())
+			require.NoError(t, err)
+			assert.Positive(t, count)
+
+			assert.Equal(t, "test-2", getStoredKeyID(result.Slug))
+			assert.Equal(t, "test-2", getStoredKeyID("plaintext-workflow"))
+
+			useKeyring(testKey2) // old key retired
+
+			assert.JSONEq(t, workflow, getWorkflow(result.Slug))
+			assert.JSONEq(t, workflow, getWorkflow("plaintext-workflow"))
+
+			count, err = api.ShortlinkService.ReencryptAll(context.Background())
+			require.NoError(t, err)
+			assert.Zero(t, count) // nothing left to re-encrypt
+		})
+
+		t.Run("should reject malformed keys", func(t *testing.T) {
+			for _, keys := range []string{"no-separator", "test-1:" + base64.StdEncoding.EncodeToString([]byte("short")), "bad id:AAAA", testKeys + "," + testKeys} {
+				_, err := services.LoadKeyring(keys, "")
+				assert.Error(t, err, keys)
+			}
+
+			filePath := filepath.Join(t.TempDir(), "keys")
+			require.NoError(t, os.WriteFile(filePath, []byte("# rotated 2026-10\n"+strings.ReplaceAll(testKeys, ",", "\n")+"\n"), 0600))
+
+			fileKeyring, err := services.LoadKeyring("", filePath)
+			require.NoError(t, err)
+			assert.Equal(t, "test-1", fileKeyring.ActiveKeyID())
+
+			noKeyring, err := services.LoadKeyring("", "")
+			require.NoError(t, err)
+			assert.Nil(t, noKeyring)
 		})
 	})
 
@@ -728,6 +3392,25 @@ func TestAPI(t *testing.T) {
 			{"delivery scam", "https://fake-fedex.com/delivery-notification", true},
 
 			{"workflow with signin", `{"nodes":[{"name":"signin-node","type":"webhook"}]}`, false},
+
+			{"allowlisted host with login path", "https://github.com/foo/login-flow", false},
+			{"host containing t.co substring", "https://reddit.com/r/n8n", false},
+			{"path containing t.co substring", "https://example.com/tips/t.co-explained", false},
+			{"keyword in subdomain only", "https://login.example.co.uk/docs", false},
+			{"brand domain", "https://www.paypal.com/donate", false},
+			{"another brand domain", "https://www.fedex.com/en-us/home.html", false},
+			{"credential word in path segment", "https://stackoverflow.com/questions/123/login-flow", false},
+			{"credential word in nested path segment", "https://docs.example.com/auth/signin-guide", false},
+
+			{"keyword in registrable domain", "https://paypal-secure.co.uk/account", true},
+			{"keyword in subdomain label", "https://secure-paypal-login.attacker.com/x", true},
+			{"credential page", "https://evil.com/login.php", true},
+			{"credential page segment", "https://evil.com/signin/portal", true},
+			{"credential page with phishing suffix", "https://evil.com/login-secure-update.html", true},
+			{"denylisted TLD", "https://free-prizes.tk/", true},
+			{"IP address host", "https://203.0.113.7/", true},
+			{"non-http scheme", "ftp://example.com/file", true},
+			{"javascript scheme", "javascript:alert(1)", true},
 		}
 
 		for _, tc := range testCases {
@@ -748,10 +3431,69 @@ func TestAPI(t *testing.T) {
 					err = json.NewDecoder(resp.Body).Decode(&errorResponse)
 					require.NoError(t, err)
 					assert.Equal(t, "CONTENT_BLOCKED", errorResponse.Error.Code)
+					assert.Contains(t, errorResponse.Error.Trace, "matched rule")
 				} else {
 					assert.Equal(t, http.StatusCreated, resp.StatusCode)
 				}
 			})
 		}
+
+		t.Run("should return matched rule in error", func(t *testing.T) {
+			body, err := json.Marshal(entities.Shortlink{Content: "https://x.cpanel.site/"})
+			require.NoError(t, err)
+
+			resp, err := http.Post(server.URL+"/shortlink", "application/json", bytes.NewBuffer(body))
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			errorResponse := toErrorResponse(resp.Body)
+			assert.Contains(t, errorResponse.Error.Trace, `"deny_hosts:cpanel.site"`)
+		})
+
+		t.Run("should hot-reload rules from file", func(t *testing.T) {
+			filePath := filepath.Join(t.TempDir(), "url-policy.json")
+
+			filePolicy, err := policy.NewEngine(filePath, &logger)
+			require.NoError(t, err)
+			assert.FileExists(t, filePath) // created with default rules
+
+			require.NoError(t, filePolicy.Evaluate("https://example.org/page"))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go filePolicy.Watch(ctx, 10*time.Millisecond)
+
+			rules := `{"schemes": ["https"], "deny_hosts": ["example.org"]}`
+			require.NoError(t, os.WriteFile(filePath, []byte(rules), 0644))
+
+			assert.Eventually(t, func() bool {
+				return filePolicy.Evaluate("https://example.org/page") != nil
+			}, time.Second, 10*time.Millisecond)
+
+			err = filePolicy.Evaluate("https://www.example.org/page")
+			var violation *policy.Violation
+			require.ErrorAs(t, err, &violation)
+			assert.Equal(t, "deny_hosts:example.org", violation.Rule)
+			assert.ErrorIs(t, err, errors.ErrContentBlocked)
+
+			require.NoError(t, os.WriteFile(filePath, []byte("{ invalid"), 0644))
+			time.Sleep(50 * time.Millisecond)
+
+			assert.Error(t, filePolicy.Evaluate("https://example.org/page")) // keeps previous rules
+		})
+
+		t.Run("should match mixed-case keywords from file", func(t *testing.T) {
+			filePath := filepath.Join(t.TempDir(), "url-policy.json")
+			rules := `{"schemes": ["https"], "deny_domain_keywords": ["PayPal-"]}`
+			require.NoError(t, os.WriteFile(filePath, []byte(rules), 0644))
+
+			filePolicy, err := policy.NewEngine(filePath, &logger)
+			require.NoError(t, err)
+
+			err = filePolicy.Evaluate("https://paypal-secure.example.com/account")
+			var violation *policy.Violation
+			require.ErrorAs(t, err, &violation)
+			assert.Equal(t, "deny_domain_keywords:paypal-", violation.Rule)
+		})
 	})
 }
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ivov/n8n-shortlink/internal/errors"
+)
+
+const (
+	exportFormatCSV    = "csv"
+	exportFormatNDJSON = "ndjson"
+
+	exportFlushEvery = 100 // rows
+)
+
+// exportWriter streams the rows of an export as CSV or NDJSON, flushing periodically
+// so that rows reach the client as they are read from the DB.
+type exportWriter struct {
+	w          http.ResponseWriter
+	controller *http.ResponseController
+	csv        *csv.Writer   // nil if NDJSON
+	json       *json.Encoder // nil if CSV
+	rows       int
+}
+
+// newExportWriter sets the headers of an export named after a file name without extension,
+// in the format of the request path's extension, and lifts the write deadline so that
+// exports are not cut short. CSV exports start with a header row.
+func newExportWriter(w http.ResponseWriter, r *http.Request, fileName string, header []string) (*exportWriter, error) {
+	ew := &exportWriter{w: w, controller: http.NewResponseController(w)}
+
+	format := exportFormatNDJSON
+	if strings.HasSuffix(r.URL.Path, "."+exportFormatCSV) {
+		format = exportFormatCSV
+	}
+
+	if err := ew.controller.SetWriteDeadline(time.Time{}); err != nil {
+		return nil, err
+	}
+
+	w.Header().Set("Content-Disposition", `attachment; filename="`+fileName+"."+format+`"`)
+
+	if format == exportFormatNDJSON {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		ew.json = json.NewEncoder(w)
+		return ew, nil
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	ew.csv = csv.NewWriter(w)
+
+	return ew, ew.csv.Write(header)
+}
+
+// write writes a row, as a CSV record or as an NDJSON line of the row's JSON encoding.
+func (ew *exportWriter) write(record []string, row any) error {
+	var err error
+	if ew.csv != nil {
+		err = ew.csv.Write(record)
+	} else {
+		err = ew.json.Encode(row)
+	}
+	if err != nil {
+		return err
+	}
+
+	ew.rows++
+	if ew.rows%exportFlushEvery == 0 {
+		return ew.flush()
+	}
+
+	return nil
+}
+
+// csvSafe prefixes with a quote a client-controlled value, such as a referer, user agent or creator IP,
+// that spreadsheets would otherwise evaluate as a formula when opening a CSV export.
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+
+	return value
+}
+
+func (ew *exportWriter) flush() error {
+	if ew.csv != nil {
+		ew.csv.Flush()
+		if err := ew.csv.Error(); err != nil {
+			return err
+		}
+	}
+
+	return ew.controller.Flush()
+}
+
+// parseExportRange parses the optional `from` and `to` query params as YYYY-MM-DD in UTC, both
+// inclusive, into bounds for comparison with timestamps, start inclusive and end exclusive,
+// each empty if unbounded.
+func parseExportRange(r *http.Request) (string, string, error) {
+	const layout = "2006-01-02"
+
+	var from, to time.Time
+
+	start := r.URL.Query().Get("from")
+	if start != "" {
+		parsed, err := time.Parse(layout, start)
+		if err != nil {
+			return "", "", errors.ErrExportRangeInvalid
+		}
+		from = parsed
+	}
+
+	end := ""
+	if rawTo := r.URL.Query().Get("to"); rawTo != "" {
+		parsed, err := time.Parse(layout, rawTo)
+		if err != nil {
+			return "", "", errors.ErrExportRangeInvalid
+		}
+		to = parsed
+		end = to.AddDate(0, 0, 1).Format(layout)
+	}
+
+	if start != "" && end != "" && from.After(to) {
+		return "", "", errors.ErrExportRangeInvalid
+	}
+
+	return start, end, nil
+}
+
+// formatExportTime formats a timestamp like the DB does, or returns an empty string if nil.
+func formatExportTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+
+	return t.UTC().Format("2006-01-02 15:04:05")
+}
@@ -0,0 +1,28 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/ivov/n8n-shortlink/internal/errors"
+)
+
+// HandleDeleteShortlinkShareTokens handles a DELETE /shortlink/{slug}/share-tokens request by rotating
+// a protected shortlink's share secret, revoking all its share tokens. Requires the shortlink's management token.
+func (api *API) HandleDeleteShortlinkShareTokens(w http.ResponseWriter, r *http.Request) {
+	shortlink := api.authorizeManagement(w, r)
+	if shortlink == nil {
+		return
+	}
+
+	if shortlink.Password == "" {
+		api.BadRequest(errors.ErrShortlinkNotProtected, w)
+		return
+	}
+
+	if err := api.ShortlinkService.RotateShareSecret(shortlink.Slug, shortlink); err != nil {
+		api.InternalServerError(err, w)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
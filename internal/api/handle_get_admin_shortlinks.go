@@ -0,0 +1,57 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ivov/n8n-shortlink/internal/db/entities"
+)
+
+var shortlinkExportHeader = []string{
+	"slug", "kind", "created_at", "expires_at", "activate_at", "creator_ip",
+	"redirect_type", "is_protected", "is_encrypted_at_rest", "visits",
+}
+
+// HandleGetAdminShortlinks handles a GET /admin/shortlinks.csv or /admin/shortlinks.ndjson request
+// by streaming the metadata of all shortlinks, excluding their content. Requires the admin token.
+func (api *API) HandleGetAdminShortlinks(w http.ResponseWriter, r *http.Request) {
+	if !api.authorizeAdmin(w, r) {
+		return
+	}
+
+	ew, err := newExportWriter(w, r, "shortlinks", shortlinkExportHeader)
+	if err != nil {
+		api.InternalServerError(err, w)
+		return
+	}
+
+	err = api.ShortlinkService.StreamShortlinks(r.Context(), func(shortlink *entities.ShortlinkExport) error {
+		return ew.write([]string{
+			shortlink.Slug,
+			shortlink.Kind,
+			formatExportTime(&shortlink.CreatedAt.Time),
+			formatExportTime(optionalTime(shortlink.ExpiresAt)),
+			formatExportTime(optionalTime(shortlink.ActivateAt)),
+			csvSafe(shortlink.CreatorIP), // from client-controlled headers
+			strconv.Itoa(shortlink.RedirectType),
+			strconv.FormatBool(shortlink.IsProtected),
+			strconv.FormatBool(shortlink.IsEncryptedAtRest),
+			strconv.Itoa(shortlink.Visits),
+		}, shortlink)
+	})
+	if err == nil {
+		err = ew.flush()
+	}
+	if err != nil {
+		api.Logger.Error(err) // response already under way, so cut short
+	}
+}
+
+func optionalTime(ct *entities.CustomTime) *time.Time {
+	if ct == nil {
+		return nil
+	}
+
+	return &ct.Time
+}
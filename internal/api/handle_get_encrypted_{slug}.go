@@ -0,0 +1,54 @@
+package api
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/ivov/n8n-shortlink/internal"
+	"github.com/ivov/n8n-shortlink/internal/db/entities"
+)
+
+// encryptedViewerPolicy keeps the viewer page, which holds the key and the decrypted workflow, from
+// loading third-party scripts or frames and from sending anything anywhere.
+const encryptedViewerPolicy = "default-src 'none'; script-src 'self'; style-src 'self' 'unsafe-inline'; " +
+	"img-src 'self'; font-src 'self'; connect-src 'none'; frame-src 'none'; base-uri 'none'; form-action 'none'"
+
+// HandleGetEncryptedSlug handles a GET /{slug} request for an encrypted shortlink, rendering a viewer
+// page that decrypts the blob in the browser with the key in the URL fragment, or returning the blob
+// as is to API clients. The server never sees the key, so it never sees the workflow.
+func (api *API) HandleGetEncryptedSlug(w http.ResponseWriter, r *http.Request, slug string, shortlink *entities.Shortlink) {
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+
+	isView := strings.HasSuffix(r.URL.Path, "/view")
+
+	if !isView && !strings.Contains(r.Header.Get("Accept"), "text/html") {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if _, err := w.Write([]byte(shortlink.Content)); err != nil {
+			api.Logger.Error(err)
+		}
+		return
+	}
+
+	tmpl, err := template.ParseFS(internal.Static(), "encrypted.tmpl.html")
+	if err != nil {
+		api.InternalServerError(err, w)
+		return
+	}
+
+	data := struct {
+		Slug string
+		Blob string
+	}{
+		Slug: slug,
+		Blob: shortlink.Content,
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", encryptedViewerPolicy)
+	err = tmpl.Execute(w, data)
+	if err != nil {
+		api.Logger.Error(err)
+	}
+}
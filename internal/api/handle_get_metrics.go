@@ -1,10 +1,15 @@
 package api
 
 import (
+	"context"
+	stdErrors "errors"
 	"expvar"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/ivov/n8n-shortlink/internal/policy"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
@@ -13,6 +18,8 @@ import (
 func (api *API) HandleGetMetrics(w http.ResponseWriter, r *http.Request) {
 	updatePrometheusMetrics() // on every scrape
 
+	visitQueueDepth.Set(float64(api.VisitService.QueueDepth()))
+
 	promhttp.HandlerFor(
 		prometheus.DefaultGatherer,
 		promhttp.HandlerOpts{
@@ -45,14 +52,144 @@ var (
 		},
 		[]string{"status"},
 	)
+	brokenShortlinks = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "broken_shortlinks",
+		Help: "Number of URL shortlinks whose destination was broken on the latest check as of the latest refresh",
+	})
+	passwordFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "password_failures_total",
+		Help: "Total number of failed password attempts on protected shortlinks",
+	})
+	visitQueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "visit_queue_depth",
+		Help: "Number of visits queued to be written",
+	})
+	droppedVisits = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "visits_dropped_total",
+		Help: "Total number of visits dropped because the visit queue was full",
+	})
+	shortlinksCreated = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "shortlinks_created_total",
+			Help: "Total number of shortlinks created by kind and whether password-protected",
+		},
+		[]string{"kind", "protected"},
+	)
+	shortlinkResolves = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "shortlink_resolves_total",
+			Help: "Total number of attempts to resolve a shortlink by kind and outcome",
+		},
+		[]string{"kind", "outcome"},
+	)
+	contentBlocked = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "content_blocked_total",
+			Help: "Total number of shortlink destinations blocked by the URL policy by matched rule",
+		},
+		[]string{"rule"},
+	)
+	storedShortlinks = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "shortlinks_stored",
+			Help: "Number of stored shortlinks by kind as of the latest refresh",
+		},
+		[]string{"kind"},
+	)
+)
+
+// Outcomes of an attempt to resolve a shortlink.
+const (
+	resolveOK           = "ok"
+	resolveNotFound     = "not_found"
+	resolveExpired      = "expired" // outside the shortlink's availability: past expiration or before activation
+	resolveUnauthorized = "unauthorized"
+	resolveThrottled    = "throttled" // after too many failed password attempts
 )
 
+// unknownKind labels attempts to resolve a slug that does not exist.
+const unknownKind = "unknown"
+
+var shortlinkKinds = []string{"url", "workflow", "encrypted"}
+
 func init() {
 	prometheus.MustRegister(totalRequestsReceived)
 	prometheus.MustRegister(totalResponsesSent)
 	prometheus.MustRegister(totalProcessingTimeMs)
 	prometheus.MustRegister(inFlightRequests)
 	prometheus.MustRegister(responsesSentByStatus)
+	prometheus.MustRegister(brokenShortlinks)
+	prometheus.MustRegister(passwordFailures)
+	prometheus.MustRegister(visitQueueDepth)
+	prometheus.MustRegister(droppedVisits)
+	prometheus.MustRegister(shortlinksCreated)
+	prometheus.MustRegister(shortlinkResolves)
+	prometheus.MustRegister(contentBlocked)
+	prometheus.MustRegister(storedShortlinks)
+}
+
+// countResolve counts an attempt to resolve a shortlink of a kind with an outcome.
+func countResolve(kind, outcome string) {
+	shortlinkResolves.WithLabelValues(kind, outcome).Inc()
+}
+
+// countBlockedContent counts a URL policy violation by its rule, if the error is one. Scheme
+// violations are counted under "schemes" since the scheme comes from the user.
+func countBlockedContent(err error) {
+	var violation *policy.Violation
+	if !stdErrors.As(err, &violation) {
+		return
+	}
+
+	rule := violation.Rule
+	if strings.HasPrefix(rule, "schemes:") {
+		rule = "schemes"
+	}
+
+	contentBlocked.WithLabelValues(rule).Inc()
+}
+
+// RefreshGauges sets the gauges that take a DB query, i.e. stored shortlinks by kind and broken shortlinks.
+func (api *API) RefreshGauges() error {
+	counts, err := api.ShortlinkService.CountByKind()
+	if err != nil {
+		return err
+	}
+
+	for _, kind := range shortlinkKinds {
+		storedShortlinks.WithLabelValues(kind).Set(float64(counts[kind]))
+	}
+
+	broken, err := api.LinkHealthService.CountBroken()
+	if err != nil {
+		return err
+	}
+
+	brokenShortlinks.Set(float64(broken))
+
+	return nil
+}
+
+// StartMetricsRefresh refreshes the gauges that take a DB query once on start and then once per
+// interval until the context is cancelled, so that scrapes do not query the DB every time.
+func (api *API) StartMetricsRefresh(ctx context.Context, interval time.Duration) {
+	if err := api.RefreshGauges(); err != nil {
+		api.Logger.Error(err)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := api.RefreshGauges(); err != nil {
+				api.Logger.Error(err)
+			}
+		}
+	}
 }
 
 func updatePrometheusMetrics() {
@@ -9,7 +9,6 @@ import (
 	"github.com/ivov/n8n-shortlink/internal"
 	"github.com/ivov/n8n-shortlink/internal/db/entities"
 	"github.com/ivov/n8n-shortlink/internal/errors"
-	"github.com/ivov/n8n-shortlink/internal/log"
 )
 
 // HandleGetProtectedSlug handles a GET /p/{slug} request by resolving a password-protected shortlink.
@@ -17,11 +16,13 @@ func (api *API) HandleGetProtectedSlug(w http.ResponseWriter, r *http.Request, s
 	authHeader := r.Header.Get("Authorization")
 
 	if authHeader == "" {
+		countResolve(shortlink.Kind, resolveUnauthorized)
 		http.ServeFileFS(w, r, internal.Static(), "challenge.html")
 		return
 	}
 
 	if !strings.HasPrefix(authHeader, "Basic ") {
+		countResolve(shortlink.Kind, resolveUnauthorized)
 		api.Unauthorized(errors.ErrAuthHeaderMalformed, w)
 		return
 	}
@@ -29,23 +30,28 @@ func (api *API) HandleGetProtectedSlug(w http.ResponseWriter, r *http.Request, s
 	encodedPassword := strings.TrimPrefix(authHeader, "Basic ")
 	decodedBytes, err := base64.StdEncoding.DecodeString(encodedPassword)
 	if err != nil {
+		countResolve(shortlink.Kind, resolveUnauthorized)
 		api.Unauthorized(errors.ErrAuthHeaderMalformed, w)
 		return
 	}
 
-	decodedPassword := string(decodedBytes)
-	if !api.ShortlinkService.VerifyPassword(shortlink.Password, decodedPassword) {
-		api.Unauthorized(errors.ErrPasswordInvalid, w)
+	if !api.verifyUnlockPassword(w, r, slug, shortlink, string(decodedBytes)) {
 		return
 	}
 
-	api.Logger.Info("password verified", log.Str("slug", slug))
+	visit := entities.Visit{Slug: slug, Referer: r.Referer(), UserAgent: r.UserAgent(), AccessPath: entities.AccessPathProtected}
 
-	err = api.VisitService.SaveVisit(slug, shortlink.Kind, r.Referer(), r.UserAgent())
-	if err != nil {
-		api.Logger.Error(err) // log and move on
+	var destination string
+	if shortlink.Kind == "url" {
+		destination, visit.Variant, err = api.resolveDestination(w, r, shortlink)
+		if err != nil {
+			api.InternalServerError(err, w)
+			return
+		}
 	}
 
+	api.saveVisit(r, visit, shortlink.Kind)
+
 	switch shortlink.Kind {
 	case "workflow":
 		w.Header().Set("Content-Type", "application/json")
@@ -54,10 +60,16 @@ func (api *API) HandleGetProtectedSlug(w http.ResponseWriter, r *http.Request, s
 		}
 	case "url":
 		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(map[string]string{"url": shortlink.Content}); err != nil {
+		if err := json.NewEncoder(w).Encode(map[string]string{"url": destination}); err != nil {
 			api.Logger.Error(err)
 			api.InternalServerError(err, w)
 		}
+	case "encrypted":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		if _, err := w.Write([]byte(shortlink.Content)); err != nil {
+			api.Logger.Error(err)
+		}
 	default:
 		api.BadRequest(errors.ErrKindUnsupported, w)
 	}
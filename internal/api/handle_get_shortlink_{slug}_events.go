@@ -0,0 +1,99 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ivov/n8n-shortlink/internal/db/entities"
+)
+
+const eventsHeartbeatInterval = 15 * time.Second
+
+// VisitEvent is a visit pushed to a live event stream.
+type VisitEvent struct {
+	TS         entities.CustomTime `json:"ts"`
+	Referer    string              `json:"referer"`
+	UserAgent  string              `json:"user_agent"`
+	Browser    string              `json:"browser"`
+	OS         string              `json:"os"`
+	Device     string              `json:"device"`
+	IsBot      bool                `json:"is_bot"`
+	AccessPath string              `json:"access_path"`
+	Variant    string              `json:"variant,omitempty"`
+}
+
+// HandleGetShortlinkEvents handles a GET /shortlink/{slug}/events request by streaming a `visit`
+// Server-Sent Event whenever the shortlink is visited, with a heartbeat comment every 15 seconds
+// to keep the connection open. Clients too slow to keep up are disconnected, to reconnect.
+// Requires the shortlink's management token.
+func (api *API) HandleGetShortlinkEvents(w http.ResponseWriter, r *http.Request) {
+	shortlink := api.authorizeManagement(w, r)
+	if shortlink == nil {
+		return
+	}
+
+	controller := http.NewResponseController(w)
+	if err := controller.SetWriteDeadline(time.Time{}); err != nil {
+		api.InternalServerError(err, w)
+		return
+	}
+
+	visits, unsubscribe := api.VisitService.Broker.Subscribe(shortlink.Slug)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // disable buffering by reverse proxy
+	w.WriteHeader(http.StatusOK)
+
+	send := func(message string) bool {
+		if _, err := fmt.Fprint(w, message); err != nil {
+			return false
+		}
+		return controller.Flush() == nil
+	}
+
+	if !send(": connected\n\n") {
+		return
+	}
+
+	ticker := time.NewTicker(eventsHeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			if !send(": heartbeat\n\n") {
+				return
+			}
+		case visit, ok := <-visits:
+			if !ok {
+				return // fell behind or shutting down
+			}
+
+			data, err := json.Marshal(VisitEvent{
+				TS:         visit.TS,
+				Referer:    visit.Referer,
+				UserAgent:  visit.UserAgent,
+				Browser:    visit.Browser,
+				OS:         visit.OS,
+				Device:     visit.Device,
+				IsBot:      visit.IsBot,
+				AccessPath: visit.AccessPath,
+				Variant:    visit.Variant,
+			})
+			if err != nil {
+				api.Logger.Error(err)
+				return
+			}
+
+			if !send("event: visit\ndata: " + string(data) + "\n\n") {
+				return
+			}
+		}
+	}
+}
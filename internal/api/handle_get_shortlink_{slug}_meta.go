@@ -0,0 +1,38 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/ivov/n8n-shortlink/internal/db/entities"
+)
+
+// ShortlinkMeta is metadata about a shortlink, excluding its content.
+type ShortlinkMeta struct {
+	Slug      string               `json:"slug"`
+	Kind      string               `json:"kind"`
+	CreatedAt entities.CustomTime  `json:"created_at"`
+	Health    *entities.LinkHealth `json:"health"` // nil if not yet checked
+}
+
+// HandleGetShortlinkMeta handles a GET /shortlink/{slug}/meta request by returning
+// a shortlink's metadata, including the latest check of its destination. Requires the
+// shortlink's management token.
+func (api *API) HandleGetShortlinkMeta(w http.ResponseWriter, r *http.Request) {
+	shortlink := api.authorizeManagement(w, r)
+	if shortlink == nil {
+		return
+	}
+
+	health, err := api.LinkHealthService.GetBySlug(shortlink.Slug)
+	if err != nil {
+		api.InternalServerError(err, w)
+		return
+	}
+
+	api.OK(w, ShortlinkMeta{
+		Slug:      shortlink.Slug,
+		Kind:      shortlink.Kind,
+		CreatedAt: shortlink.CreatedAt,
+		Health:    health,
+	})
+}
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/ivov/n8n-shortlink/internal/db/entities"
+)
+
+// RoutingRulesPayload is the payload for reading and replacing a shortlink's routing rules.
+type RoutingRulesPayload struct {
+	Rules entities.RoutingRules `json:"rules"`
+}
+
+// HandleGetShortlinkRules handles a GET /shortlink/{slug}/rules request by returning
+// a URL shortlink's routing rules. Requires the shortlink's management token.
+func (api *API) HandleGetShortlinkRules(w http.ResponseWriter, r *http.Request) {
+	shortlink := api.authorizeManagement(w, r)
+	if shortlink == nil {
+		return
+	}
+
+	if _, ok := api.decryptManaged(w, shortlink); !ok {
+		return
+	}
+
+	rules := shortlink.RoutingRules
+	if rules == nil {
+		rules = entities.RoutingRules{}
+	}
+
+	api.OK(w, RoutingRulesPayload{Rules: rules})
+}
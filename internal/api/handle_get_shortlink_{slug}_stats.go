@@ -0,0 +1,68 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/ivov/n8n-shortlink/internal/errors"
+)
+
+const (
+	defaultStatsRangeDays = 30
+	maxStatsRangeDays     = 366
+)
+
+// HandleGetShortlinkStats handles a GET /shortlink/{slug}/stats request by returning a summary of
+// a shortlink's visits over a range of days, set by `from` and `to` query params as YYYY-MM-DD in UTC,
+// both inclusive and defaulting to the last 30 days. Bots are excluded if `exclude_bots` is true.
+// Requires the shortlink's management token.
+func (api *API) HandleGetShortlinkStats(w http.ResponseWriter, r *http.Request) {
+	shortlink := api.authorizeManagement(w, r)
+	if shortlink == nil {
+		return
+	}
+
+	from, to, err := parseStatsRange(r)
+	if err != nil {
+		api.BadRequest(err, w)
+		return
+	}
+
+	excludeBots := r.URL.Query().Get("exclude_bots") == "true"
+
+	stats, err := api.VisitService.GetStats(shortlink.Slug, from, to, excludeBots)
+	if err != nil {
+		api.InternalServerError(err, w)
+		return
+	}
+
+	api.OK(w, stats)
+}
+
+func parseStatsRange(r *http.Request) (time.Time, time.Time, error) {
+	const layout = "2006-01-02"
+
+	to := time.Now().UTC().Truncate(24 * time.Hour)
+	if rawTo := r.URL.Query().Get("to"); rawTo != "" {
+		parsed, err := time.Parse(layout, rawTo)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.ErrStatsRangeInvalid
+		}
+		to = parsed
+	}
+
+	from := to.AddDate(0, 0, -(defaultStatsRangeDays - 1))
+	if rawFrom := r.URL.Query().Get("from"); rawFrom != "" {
+		parsed, err := time.Parse(layout, rawFrom)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.ErrStatsRangeInvalid
+		}
+		from = parsed
+	}
+
+	if from.After(to) || to.Sub(from) >= maxStatsRangeDays*24*time.Hour {
+		return time.Time{}, time.Time{}, errors.ErrStatsRangeInvalid
+	}
+
+	return from, to, nil
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ivov/n8n-shortlink/internal/db/entities"
+)
+
+var visitExportHeader = []string{
+	"id", "ts", "referer", "user_agent", "variant", "access_path",
+	"browser", "os", "device", "is_bot", "visitor_hash",
+}
+
+// HandleGetShortlinkVisits handles a GET /shortlink/{slug}/visits.csv or /shortlink/{slug}/visits.ndjson
+// request by streaming a shortlink's visits, optionally limited by `from` and `to` query params as
+// YYYY-MM-DD in UTC, both inclusive. Requires the shortlink's management token.
+func (api *API) HandleGetShortlinkVisits(w http.ResponseWriter, r *http.Request) {
+	shortlink := api.authorizeManagement(w, r)
+	if shortlink == nil {
+		return
+	}
+
+	start, end, err := parseExportRange(r)
+	if err != nil {
+		api.BadRequest(err, w)
+		return
+	}
+
+	ew, err := newExportWriter(w, r, shortlink.Slug+"-visits", visitExportHeader)
+	if err != nil {
+		api.InternalServerError(err, w)
+		return
+	}
+
+	err = api.VisitService.StreamVisits(r.Context(), shortlink.Slug, start, end, func(visit *entities.Visit) error {
+		return ew.write([]string{
+			strconv.Itoa(visit.ID),
+			formatExportTime(&visit.TS.Time),
+			csvSafe(visit.Referer),
+			csvSafe(visit.UserAgent),
+			visit.Variant,
+			visit.AccessPath,
+			visit.Browser,
+			visit.OS,
+			visit.Device,
+			strconv.FormatBool(visit.IsBot),
+			visit.VisitorHash,
+		}, visit)
+	})
+	if err == nil {
+		err = ew.flush()
+	}
+	if err != nil {
+		api.Logger.Error(err) // response already under way, so cut short
+	}
+}
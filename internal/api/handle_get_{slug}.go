@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/ivov/n8n-shortlink/internal"
+	"github.com/ivov/n8n-shortlink/internal/db/entities"
 	"github.com/ivov/n8n-shortlink/internal/errors"
 )
 
@@ -17,6 +18,7 @@ func (api *API) HandleGetSlug(w http.ResponseWriter, r *http.Request) {
 	shortlink, err := api.ShortlinkService.GetBySlug(slug)
 	if err != nil {
 		if stdErrors.Is(err, errors.ErrShortlinkNotFound) {
+			countResolve(unknownKind, resolveNotFound)
 			w.WriteHeader(http.StatusNotFound)
 			http.ServeFileFS(w, r, internal.Static(), "404.html")
 		} else {
@@ -25,16 +27,67 @@ func (api *API) HandleGetSlug(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if shortlink.Password != "" {
-		api.HandleGetProtectedSlug(w, r, slug, shortlink)
+	if api.ShortlinkService.IsExpired(shortlink) {
+		countResolve(shortlink.Kind, resolveExpired)
+		w.WriteHeader(http.StatusNotFound)
+		http.ServeFileFS(w, r, internal.Static(), "404.html")
 		return
 	}
 
-	err = api.VisitService.SaveVisit(slug, shortlink.Kind, r.Referer(), r.UserAgent())
-	if err != nil {
-		api.Logger.Error(err) // log and move on
+	if !api.ShortlinkService.IsActive(shortlink) {
+		countResolve(shortlink.Kind, resolveExpired)
+		api.HandleGetInactiveSlug(w, r, slug, shortlink)
+		return
+	}
+
+	isPreview := strings.HasSuffix(r.URL.Path, "/preview")
+
+	if isPreview && shortlink.Kind != "url" {
+		countResolve(shortlink.Kind, resolveNotFound)
+		w.WriteHeader(http.StatusNotFound)
+		http.ServeFileFS(w, r, internal.Static(), "404.html")
+		return
+	}
+
+	if shortlink.Password != "" {
+		contentKey, isUnlocked := api.hasUnlockSession(r, slug, shortlink)
+		if !isUnlocked {
+			contentKey, isUnlocked = api.hasShareToken(r, slug, shortlink)
+		}
+
+		if !isUnlocked {
+			api.HandleGetProtectedSlug(w, r, slug, shortlink)
+			return
+		}
+
+		if err := api.ShortlinkService.DecryptContent(shortlink, contentKey); err != nil {
+			api.InternalServerError(err, w)
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store") // keep browsers from serving it after the session ends
+	}
+
+	visit := entities.Visit{Slug: slug, Referer: r.Referer(), UserAgent: r.UserAgent(), AccessPath: entities.AccessPathRaw}
+
+	switch {
+	case shortlink.Password != "":
+		visit.AccessPath = entities.AccessPathProtected
+	case isPreview || strings.HasSuffix(r.URL.Path, "/view") || shortlink.ForcePreview:
+		visit.AccessPath = entities.AccessPathView
 	}
 
+	var destination string
+	if shortlink.Kind == "url" {
+		destination, visit.Variant, err = api.resolveDestination(w, r, shortlink)
+		if err != nil {
+			api.InternalServerError(err, w)
+			return
+		}
+	}
+
+	api.saveVisit(r, visit, shortlink.Kind)
+
 	switch shortlink.Kind {
 	case "workflow":
 		if strings.HasSuffix(r.URL.Path, "/view") {
@@ -65,8 +118,82 @@ func (api *API) HandleGetSlug(w http.ResponseWriter, r *http.Request) {
 			api.Logger.Error(err)
 		}
 	case "url":
-		http.Redirect(w, r, shortlink.Content, http.StatusMovedPermanently)
+		if isPreview || shortlink.ForcePreview {
+			api.HandleGetSlugPreview(w, r, slug, shortlink, destination)
+			return
+		}
+
+		http.Redirect(w, r, destination, shortlink.RedirectType)
+	case "encrypted":
+		api.HandleGetEncryptedSlug(w, r, slug, shortlink)
 	default:
 		api.BadRequest(errors.ErrKindUnsupported, w)
 	}
 }
+
+// resolveDestination picks the destination of a URL shortlink for a visitor based on the shortlink's
+// routing rules and A/B split, and builds the URL to send the visitor to. Also returns the name of the
+// picked variant, or an empty string if the visitor was routed by a rule or there is no split.
+func (api *API) resolveDestination(w http.ResponseWriter, r *http.Request, shortlink *entities.Shortlink) (string, string, error) {
+	if len(shortlink.RoutingRules) > 0 {
+		w.Header().Set("Vary", "Accept-Language, User-Agent, Referer")
+	}
+
+	destination, isRouted := api.ShortlinkService.RouteDestination(
+		shortlink,
+		r.Header.Get("Accept-Language"),
+		r.UserAgent(),
+		r.Referer(),
+	)
+
+	variantName := ""
+	if !isRouted && len(shortlink.Variants) > 0 {
+		variant := api.pickVariant(w, r, shortlink)
+		destination, variantName = variant.Destination, variant.Name
+	}
+
+	query := r.URL.Query()
+	if shortlink.Password != "" {
+		query.Del(shareTokenParam) // keep share tokens from leaking to destinations
+	}
+
+	redirectURL, err := api.ShortlinkService.BuildRedirectURL(shortlink, destination, query)
+	if err != nil {
+		return "", "", err
+	}
+
+	return redirectURL, variantName, nil
+}
+
+const (
+	variantCookieName   = "n8n_shortlink_variant"
+	variantCookieMaxAge = 30 * 24 * 60 * 60 // 30 days
+)
+
+// pickVariant picks a variant of a shortlink's A/B split for a visitor. If the split is sticky,
+// the visitor is kept on the variant in their cookie, scoped to the shortlink's path.
+func (api *API) pickVariant(w http.ResponseWriter, r *http.Request, shortlink *entities.Shortlink) entities.Variant {
+	w.Header().Set("Cache-Control", "no-store") // keep browsers from caching a single variant's redirect
+
+	if !shortlink.StickyVariants {
+		return api.ShortlinkService.PickVariant(shortlink.Variants, "")
+	}
+
+	stickyName := ""
+	if cookie, err := r.Cookie(variantCookieName); err == nil {
+		stickyName = cookie.Value
+	}
+
+	variant := api.ShortlinkService.PickVariant(shortlink.Variants, stickyName)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     variantCookieName,
+		Value:    variant.Name,
+		Path:     "/" + r.PathValue("slug"),
+		MaxAge:   variantCookieMaxAge,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return variant
+}
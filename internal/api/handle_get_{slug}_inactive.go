@@ -0,0 +1,42 @@
+package api
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/ivov/n8n-shortlink/internal"
+	"github.com/ivov/n8n-shortlink/internal/db/entities"
+)
+
+// HandleGetInactiveSlug handles a GET /{slug} request for a shortlink whose activation time has not
+// yet arrived, rendering a "not yet available" page for browsers and a 404 for API clients.
+func (api *API) HandleGetInactiveSlug(w http.ResponseWriter, r *http.Request, slug string, shortlink *entities.Shortlink) {
+	w.Header().Set("Cache-Control", "no-store") // keep the shortlink from staying unavailable past activation
+
+	if !strings.Contains(r.Header.Get("Accept"), "text/html") {
+		api.NotFound(w)
+		return
+	}
+
+	tmpl, err := template.ParseFS(internal.Static(), "inactive.tmpl.html")
+	if err != nil {
+		api.InternalServerError(err, w)
+		return
+	}
+
+	data := struct {
+		Slug       string
+		ActivateAt string
+	}{
+		Slug:       slug,
+		ActivateAt: shortlink.ActivateAt.UTC().Format("2 January 2006, 15:04 UTC"),
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusNotFound)
+	err = tmpl.Execute(w, data)
+	if err != nil {
+		api.Logger.Error(err)
+	}
+}
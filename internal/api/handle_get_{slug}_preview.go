@@ -0,0 +1,44 @@
+package api
+
+import (
+	"html/template"
+	"net/http"
+	"net/url"
+
+	"github.com/ivov/n8n-shortlink/internal"
+	"github.com/ivov/n8n-shortlink/internal/db/entities"
+)
+
+// HandleGetSlugPreview handles a GET /{slug}/preview request by rendering an interstitial
+// for a URL shortlink, showing its destination instead of redirecting to it.
+func (api *API) HandleGetSlugPreview(w http.ResponseWriter, r *http.Request, slug string, shortlink *entities.Shortlink, destination string) {
+	destinationURL, err := url.Parse(destination)
+	if err != nil {
+		api.InternalServerError(err, w)
+		return
+	}
+
+	tmpl, err := template.ParseFS(internal.Static(), "preview.tmpl.html")
+	if err != nil {
+		api.InternalServerError(err, w)
+		return
+	}
+
+	data := struct {
+		Slug      string
+		Host      string
+		URL       string
+		CreatedAt string
+	}{
+		Slug:      slug,
+		Host:      destinationURL.Hostname(),
+		URL:       destination,
+		CreatedAt: shortlink.CreatedAt.Format("2 January 2006, 15:04 UTC"),
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err = tmpl.Execute(w, data)
+	if err != nil {
+		api.InternalServerError(err, w)
+	}
+}
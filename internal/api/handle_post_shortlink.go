@@ -5,6 +5,7 @@ import (
 	stdErrors "errors"
 	"net/http"
 	"net/url"
+	"strconv"
 
 	"github.com/ivov/n8n-shortlink/internal/db/entities"
 	"github.com/ivov/n8n-shortlink/internal/errors"
@@ -38,8 +39,12 @@ func (api *API) HandlePostShortlink(w http.ResponseWriter, r *http.Request) {
 
 	// check kind
 
-	_, err = url.ParseRequestURI(candidate.Content)
-	if err == nil {
+	if candidate.Kind == "encrypted" {
+		if err := api.ShortlinkService.ValidateEncryptedBlob(candidate.Content); err != nil {
+			api.BadRequest(err, w)
+			return
+		}
+	} else if _, err = url.ParseRequestURI(candidate.Content); err == nil {
 		candidate.Kind = "url"
 	} else {
 		var unmarshaled interface{}
@@ -57,11 +62,68 @@ func (api *API) HandlePostShortlink(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	candidate.RedirectChain = nil
+
+	// bound resolving all destinations below, which may each redirect through other shorteners
+	validationCtx, cancelValidation := api.ShortlinkService.WithValidationDeadline(r.Context())
+	defer cancelValidation()
+
 	if candidate.Kind == "url" {
-		if err := api.ShortlinkService.ValidateContent(candidate.Content); err != nil {
-			api.BadRequest(errors.ErrContentBlocked, w)
+		chain, err := api.ShortlinkService.ValidateDestination(validationCtx, candidate.Content)
+		if err != nil {
+			countBlockedContent(err)
+			api.BadRequest(err, w)
 			return
 		}
+		candidate.RedirectChain = chain
+	}
+
+	// check or default redirect type
+
+	if candidate.RedirectType == 0 {
+		candidate.RedirectType = api.Config.Redirect.DefaultType
+	}
+
+	if err := api.ShortlinkService.ValidateRedirectType(candidate.RedirectType); err != nil {
+		api.BadRequest(err, w)
+		return
+	}
+
+	// check routing rules
+
+	if len(candidate.RoutingRules) > 0 {
+		if candidate.Kind != "url" {
+			api.BadRequest(errors.ErrRoutingRulesInvalid, w)
+			return
+		}
+
+		if err := api.ShortlinkService.ValidateRoutingRules(validationCtx, candidate.RoutingRules); err != nil {
+			countBlockedContent(err)
+			api.BadRequest(err, w)
+			return
+		}
+	}
+
+	// check A/B split variants
+
+	if len(candidate.Variants) > 0 {
+		if candidate.Kind != "url" {
+			api.BadRequest(errors.ErrVariantsInvalid, w)
+			return
+		}
+
+		if err := api.ShortlinkService.ValidateVariants(validationCtx, candidate.Variants); err != nil {
+			countBlockedContent(err)
+			api.BadRequest(err, w)
+			return
+		}
+	}
+
+	// check UTM params
+
+	if err := api.ShortlinkService.ValidateUTMParams(candidate.UTMParams); err != nil {
+		api.BadRequest(err, w)
+		return
 	}
 
 	// check or generate slug
@@ -96,6 +158,8 @@ func (api *API) HandlePostShortlink(w http.ResponseWriter, r *http.Request) {
 
 	// check and hash password if provided
 
+	password := candidate.Password
+
 	if candidate.Password != "" {
 		if err := api.ShortlinkService.ValidatePasswordLength(candidate.Password); err != nil {
 			api.BadRequest(err, w)
@@ -115,13 +179,37 @@ func (api *API) HandlePostShortlink(w http.ResponseWriter, r *http.Request) {
 
 	candidate.CreatorIP = realip.FromRequest(r)
 
+	managementToken, managementTokenHash, err := api.ShortlinkService.GenerateManagementToken()
+	if err != nil {
+		api.InternalServerError(err, w)
+		return
+	}
+	candidate.ManagementToken = managementToken
+	candidate.ManagementTokenHash = managementTokenHash
+
+	// encrypt content at rest if protected
+
+	content, routingRules, variants := candidate.Content, candidate.RoutingRules, candidate.Variants
+
+	if password != "" {
+		if err := api.ShortlinkService.EncryptContent(&candidate, password); err != nil {
+			api.InternalServerError(err, w)
+			return
+		}
+	}
+
 	shortlink, err := api.ShortlinkService.SaveShortlink(&candidate)
 	if err != nil {
 		api.InternalServerError(err, w)
 		return
 	}
 
-	shortlink.Password = "" // do not return the password
+	shortlinksCreated.WithLabelValues(shortlink.Kind, strconv.FormatBool(password != "")).Inc()
+
+	shortlink.Password = ""     // do not return the password
+	shortlink.Content = content // return the content as submitted, not as stored
+	shortlink.RoutingRules = routingRules
+	shortlink.Variants = variants
 
 	api.CreatedSuccesfully(w, shortlink)
 }
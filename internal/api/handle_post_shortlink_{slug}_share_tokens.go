@@ -0,0 +1,95 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/ivov/n8n-shortlink/internal/db/entities"
+	"github.com/ivov/n8n-shortlink/internal/errors"
+)
+
+const (
+	shareTokenParam      = "t"
+	defaultShareTokenTTL = 24 * time.Hour
+	maxShareTokenTTL     = 30 * 24 * time.Hour
+)
+
+// ShareTokenRequest is the payload for minting a share token. An omitted TTL defaults to 24 hours.
+type ShareTokenRequest struct {
+	TTLSeconds int `json:"ttl_seconds,omitempty"`
+}
+
+// ShareToken grants access to a protected shortlink without its password until it expires.
+type ShareToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Path      string    `json:"path"` // shortlink path with the token, e.g. /my-slug?t=...
+}
+
+// HandlePostShortlinkShareTokens handles a POST /shortlink/{slug}/share-tokens request by minting
+// a time-limited token for a password-protected shortlink. Requires the shortlink's management token.
+func (api *API) HandlePostShortlinkShareTokens(w http.ResponseWriter, r *http.Request) {
+	shortlink := api.authorizeManagement(w, r)
+	if shortlink == nil {
+		return
+	}
+
+	if shortlink.Password == "" {
+		api.BadRequest(errors.ErrShortlinkNotProtected, w)
+		return
+	}
+
+	const maxPayloadSize = 4 * 1024 // 4 KB
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxPayloadSize)
+
+	var payload ShareTokenRequest
+
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			api.BadRequest(errors.ErrPayloadMalformed, w)
+			return
+		}
+	}
+
+	ttl := defaultShareTokenTTL
+	if payload.TTLSeconds != 0 {
+		ttl = time.Duration(payload.TTLSeconds) * time.Second
+	}
+
+	if ttl <= 0 || ttl > maxShareTokenTTL {
+		api.BadRequest(errors.ErrShareTokenTTLInvalid, w)
+		return
+	}
+
+	contentKey, err := api.ShortlinkService.ContentKeyFromManagementToken(shortlink, shortlink.ManagementToken)
+	if err != nil {
+		api.InternalServerError(err, w)
+		return
+	}
+
+	token, expiresAt, err := api.ShortlinkService.MintShareToken(shortlink.Slug, shortlink, ttl, contentKey)
+	if err != nil {
+		api.InternalServerError(err, w)
+		return
+	}
+
+	api.CreatedSuccesfully(w, ShareToken{
+		Token:     token,
+		ExpiresAt: expiresAt.UTC(),
+		Path:      "/" + shortlink.Slug + "?" + url.Values{shareTokenParam: {token}}.Encode(),
+	})
+}
+
+// hasShareToken checks if a request carries a valid share token for a protected shortlink,
+// and returns the content key it carries, which is nil for unencrypted shortlinks.
+func (api *API) hasShareToken(r *http.Request, slug string, shortlink *entities.Shortlink) ([]byte, bool) {
+	token := r.URL.Query().Get(shareTokenParam)
+	if token == "" {
+		return nil, false
+	}
+
+	return api.ShortlinkService.VerifyShareToken(slug, shortlink, token)
+}
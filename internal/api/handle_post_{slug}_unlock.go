@@ -0,0 +1,105 @@
+package api
+
+import (
+	"encoding/json"
+	stdErrors "errors"
+	"net/http"
+
+	"github.com/ivov/n8n-shortlink/internal/db/entities"
+	"github.com/ivov/n8n-shortlink/internal/errors"
+)
+
+// UnlockPayload is the body of a request to unlock a protected shortlink.
+type UnlockPayload struct {
+	Password string `json:"password"`
+	// SessionOnly skips returning the content and recording a visit, only setting the unlock cookie,
+	// e.g. for a browser to then reload the shortlink, which records the visit.
+	SessionOnly bool `json:"session_only"`
+}
+
+// UnlockedShortlink is the content of an unlocked shortlink, with either a URL, a workflow or an encrypted blob.
+type UnlockedShortlink struct {
+	Slug     string          `json:"slug"`
+	Kind     string          `json:"kind"`
+	URL      string          `json:"url,omitempty"`
+	Workflow json.RawMessage `json:"workflow,omitempty"`
+	Blob     string          `json:"blob,omitempty"` // to decrypt in the browser with the key in the URL fragment
+}
+
+// HandlePostSlugUnlock handles a POST /{slug}/unlock request by resolving a password-protected
+// shortlink with the password in a JSON body. Unprotected shortlinks resolve without a password.
+func (api *API) HandlePostSlugUnlock(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+
+	shortlink, err := api.ShortlinkService.GetBySlug(slug)
+	if err != nil {
+		if stdErrors.Is(err, errors.ErrShortlinkNotFound) {
+			countResolve(unknownKind, resolveNotFound)
+			api.NotFound(w)
+		} else {
+			api.InternalServerError(err, w)
+		}
+		return
+	}
+
+	if api.ShortlinkService.IsExpired(shortlink) || !api.ShortlinkService.IsActive(shortlink) {
+		countResolve(shortlink.Kind, resolveExpired)
+		api.NotFound(w)
+		return
+	}
+
+	var payload UnlockPayload
+
+	if shortlink.Password != "" {
+		const maxPayloadSize = 4 * 1024 // 4 KB
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxPayloadSize)
+
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			api.BadRequest(errors.ErrPayloadMalformed, w)
+			return
+		}
+
+		if payload.Password == "" {
+			countResolve(shortlink.Kind, resolveUnauthorized)
+			api.BadRequest(errors.ErrPasswordMissing, w)
+			return
+		}
+
+		if !api.verifyUnlockPassword(w, r, slug, shortlink, payload.Password) {
+			return
+		}
+	}
+
+	unlocked := UnlockedShortlink{Slug: slug, Kind: shortlink.Kind}
+
+	if shortlink.Password != "" && payload.SessionOnly {
+		api.OK(w, unlocked)
+		return
+	}
+
+	visit := entities.Visit{Slug: slug, Referer: r.Referer(), UserAgent: r.UserAgent(), AccessPath: entities.AccessPathRaw}
+	if shortlink.Password != "" {
+		visit.AccessPath = entities.AccessPathProtected
+	}
+
+	switch shortlink.Kind {
+	case "workflow":
+		unlocked.Workflow = json.RawMessage(shortlink.Content)
+	case "url":
+		unlocked.URL, visit.Variant, err = api.resolveDestination(w, r, shortlink)
+		if err != nil {
+			api.InternalServerError(err, w)
+			return
+		}
+	case "encrypted":
+		unlocked.Blob = shortlink.Content
+	default:
+		api.BadRequest(errors.ErrKindUnsupported, w)
+		return
+	}
+
+	api.saveVisit(r, visit, shortlink.Kind)
+
+	api.OK(w, unlocked)
+}
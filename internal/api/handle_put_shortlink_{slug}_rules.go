@@ -0,0 +1,58 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ivov/n8n-shortlink/internal/db/entities"
+	"github.com/ivov/n8n-shortlink/internal/errors"
+)
+
+// HandlePutShortlinkRules handles a PUT /shortlink/{slug}/rules request by replacing
+// a URL shortlink's routing rules. Requires the shortlink's management token.
+func (api *API) HandlePutShortlinkRules(w http.ResponseWriter, r *http.Request) {
+	shortlink := api.authorizeManagement(w, r)
+	if shortlink == nil {
+		return
+	}
+
+	if shortlink.Kind != "url" {
+		api.BadRequest(errors.ErrRoutingRulesInvalid, w)
+		return
+	}
+
+	const maxPayloadSize = 64 * 1024 // 64 KB
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxPayloadSize)
+
+	var payload RoutingRulesPayload
+
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		api.BadRequest(errors.ErrRoutingRulesInvalid, w)
+		return
+	}
+
+	validationCtx, cancelValidation := api.ShortlinkService.WithValidationDeadline(r.Context())
+	defer cancelValidation()
+
+	if err := api.ShortlinkService.ValidateRoutingRules(validationCtx, payload.Rules); err != nil {
+		api.BadRequest(err, w)
+		return
+	}
+
+	contentKey, ok := api.decryptManaged(w, shortlink) // to keep the variants when encrypting the new rules
+	if !ok {
+		return
+	}
+
+	if err := api.ShortlinkService.UpdateRoutingRules(shortlink, payload.Rules, contentKey); err != nil {
+		api.InternalServerError(err, w)
+		return
+	}
+
+	if payload.Rules == nil {
+		payload.Rules = entities.RoutingRules{}
+	}
+
+	api.OK(w, payload)
+}
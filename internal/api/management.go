@@ -0,0 +1,99 @@
+package api
+
+import (
+	"crypto/subtle"
+	stdErrors "errors"
+	"net/http"
+	"strings"
+
+	"github.com/ivov/n8n-shortlink/internal/db/entities"
+	"github.com/ivov/n8n-shortlink/internal/errors"
+)
+
+// authorizeManagement retrieves the shortlink of a management request and checks
+// the request's `Authorization: Bearer <management token>` header against it.
+// On failure, it responds with an error and returns nil.
+func (api *API) authorizeManagement(w http.ResponseWriter, r *http.Request) *entities.Shortlink {
+	slug := r.PathValue("slug")
+
+	shortlink, err := api.ShortlinkService.GetBySlug(slug)
+	if err != nil {
+		if stdErrors.Is(err, errors.ErrShortlinkNotFound) {
+			api.NotFound(w)
+		} else {
+			api.InternalServerError(err, w)
+		}
+		return nil
+	}
+
+	authHeader := r.Header.Get("Authorization")
+
+	if authHeader == "" {
+		api.Unauthorized(errors.ErrAuthHeaderMissing, w)
+		return nil
+	}
+
+	token, ok := strings.CutPrefix(authHeader, "Bearer ")
+	if !ok {
+		api.Unauthorized(errors.ErrAuthHeaderMalformed, w)
+		return nil
+	}
+
+	if !api.ShortlinkService.VerifyManagementToken(shortlink.ManagementTokenHash, token) {
+		api.Unauthorized(errors.ErrManagementTokenInvalid, w)
+		return nil
+	}
+
+	shortlink.Slug = slug
+	shortlink.ManagementToken = token // to unwrap the content key, not returned
+
+	return shortlink
+}
+
+// decryptManaged decrypts a protected shortlink's content, routing rules and variants in place with the
+// management token of an authorized request, and returns its content key, or nil if not protected.
+// On failure, it responds with an error and returns false.
+func (api *API) decryptManaged(w http.ResponseWriter, shortlink *entities.Shortlink) ([]byte, bool) {
+	contentKey, err := api.ShortlinkService.ContentKeyFromManagementToken(shortlink, shortlink.ManagementToken)
+	if err != nil {
+		api.InternalServerError(err, w)
+		return nil, false
+	}
+
+	if err := api.ShortlinkService.DecryptContent(shortlink, contentKey); err != nil {
+		api.InternalServerError(err, w)
+		return nil, false
+	}
+
+	return contentKey, true
+}
+
+// authorizeAdmin checks the request's `Authorization: Bearer <admin token>` header against
+// the configured admin token. Without an admin token, admin endpoints respond with a 404.
+// On failure, it responds with an error and returns false.
+func (api *API) authorizeAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if api.Config.Admin.Token == "" {
+		api.NotFound(w)
+		return false
+	}
+
+	authHeader := r.Header.Get("Authorization")
+
+	if authHeader == "" {
+		api.Unauthorized(errors.ErrAuthHeaderMissing, w)
+		return false
+	}
+
+	token, ok := strings.CutPrefix(authHeader, "Bearer ")
+	if !ok {
+		api.Unauthorized(errors.ErrAuthHeaderMalformed, w)
+		return false
+	}
+
+	if subtle.ConstantTimeCompare([]byte(token), []byte(api.Config.Admin.Token)) != 1 {
+		api.Unauthorized(errors.ErrAdminTokenInvalid, w)
+		return false
+	}
+
+	return true
+}
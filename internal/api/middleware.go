@@ -6,6 +6,7 @@ import (
 	"expvar"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"sync"
@@ -75,8 +76,8 @@ func (api *API) addRelaxedCorsHeaders(next http.Handler) http.Handler {
 	return http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set("Access-Control-Allow-Origin", "*")
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Referer, User-Agent")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Referer, User-Agent, Authorization")
 
 			next.ServeHTTP(w, r)
 		},
@@ -172,6 +173,12 @@ func (rw *wrappedResponseWriter) Write(b []byte) (int, error) {
 	return bytes, err
 }
 
+// Unwrap returns the underlying response writer, so that http.ResponseController can reach it,
+// e.g. to flush streamed responses.
+func (rw *wrappedResponseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 func isLogIgnored(path string) bool {
 	for _, denyExact := range []string{"/health", "/favicon.ico", "/metrics"} {
 		if path == denyExact || strings.HasPrefix(path, "/static/") {
@@ -181,6 +188,14 @@ func isLogIgnored(path string) bool {
 	return false
 }
 
+// redactQuery hides share tokens from logged query strings.
+func redactQuery(query url.Values) url.Values {
+	if query.Has(shareTokenParam) {
+		query.Set(shareTokenParam, "redacted")
+	}
+	return query
+}
+
 func (api *API) logRequest(next http.Handler) http.Handler {
 	return http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
@@ -207,7 +222,7 @@ func (api *API) logRequest(next http.Handler) http.Handler {
 					Protocol:    r.Proto,
 					Method:      r.Method,
 					Path:        r.URL.Path,
-					QueryString: r.URL.Query().Encode(),
+					QueryString: redactQuery(r.URL.Query()).Encode(),
 					Latency:     int(time.Duration(time.Since(start).Milliseconds())),
 					Status:      wrappedWriter.statusCode,
 					SizeBytes:   wrappedWriter.bytesWritten,
@@ -2,7 +2,10 @@ package api
 
 import (
 	"encoding/json"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/getsentry/sentry-go"
 	"github.com/ivov/n8n-shortlink/internal/errors"
@@ -15,13 +18,18 @@ func (api *API) jsonResponse(w http.ResponseWriter, status int, payload interfac
 		api.InternalServerError(err, w)
 	}
 
-	w.WriteHeader(status)
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
 	if _, err := w.Write(json); err != nil {
 		api.Logger.Error(err)
 	}
 }
 
+// OK responds with a 200.
+func (api *API) OK(w http.ResponseWriter, payload interface{}) {
+	api.jsonResponse(w, http.StatusOK, SuccessResponse{Data: payload})
+}
+
 // CreatedSuccesfully responds with a 201.
 func (api *API) CreatedSuccesfully(w http.ResponseWriter, payload interface{}) {
 	api.jsonResponse(w, http.StatusCreated, SuccessResponse{Data: payload})
@@ -52,7 +60,7 @@ func (api *API) BadRequest(err error, w http.ResponseWriter) {
 	errorResponse := ErrorResponse{
 		Error: ErrorField{
 			Message: "Your request is invalid. Please correct the request and retry.",
-			Code:    errors.ToCode[err],
+			Code:    errors.Code(err),
 			Doc:     "https://developer.mozilla.org/en-US/docs/Web/HTTP/Status/400",
 			Trace:   err.Error(),
 		},
@@ -66,6 +74,7 @@ func (api *API) NotFound(w http.ResponseWriter) {
 	errorResponse := ErrorResponse{
 		Error: ErrorField{
 			Message: "The requested resource could not be found.",
+			Code:    errors.Code(errors.ErrShortlinkNotFound),
 			Doc:     "https://developer.mozilla.org/en-US/docs/Web/HTTP/Status/404",
 			Trace:   "n/a",
 		},
@@ -89,12 +98,28 @@ func (api *API) RateLimitExceeded(w http.ResponseWriter, ip string) {
 	api.jsonResponse(w, http.StatusTooManyRequests, errorResponse)
 }
 
+// UnlockThrottled responds with a 429 and the seconds until the next password attempt is allowed.
+func (api *API) UnlockThrottled(w http.ResponseWriter, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+
+	errorResponse := ErrorResponse{
+		Error: ErrorField{
+			Message: "Too many failed password attempts. Please wait and retry later.",
+			Code:    errors.Code(errors.ErrUnlockThrottled),
+			Doc:     "https://developer.mozilla.org/en-US/docs/Web/HTTP/Status/429",
+			Trace:   "n/a",
+		},
+	}
+
+	api.jsonResponse(w, http.StatusTooManyRequests, errorResponse)
+}
+
 // Unauthorized responds with a 401.
 func (api *API) Unauthorized(err error, w http.ResponseWriter) {
 	payload := ErrorResponse{
 		Error: ErrorField{
 			Message: "Missing valid authentication credentials.",
-			Code:    errors.ToCode[err],
+			Code:    errors.Code(err),
 			Doc:     "https://developer.mozilla.org/en-US/docs/Web/HTTP/Status/401",
 			Trace:   "n/a",
 		},
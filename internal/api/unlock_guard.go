@@ -0,0 +1,213 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ivov/n8n-shortlink/internal/db/entities"
+	"github.com/ivov/n8n-shortlink/internal/errors"
+	"github.com/ivov/n8n-shortlink/internal/log"
+	"github.com/tomasen/realip"
+)
+
+// unlockGuard tracks failed password attempts on protected shortlinks, both per slug
+// and per client IP, to throttle them with exponential backoff and then a lockout.
+// The zero value is ready to use.
+type unlockGuard struct {
+	mutex    sync.Mutex
+	attempts map[string]*failedAttempts // keyed by "slug:<slug>" or "ip:<ip>"
+}
+
+type failedAttempts struct {
+	count        int
+	lastFailure  time.Time
+	blockedUntil time.Time
+}
+
+// verifyUnlockPassword checks a password attempt on a protected shortlink, subject to throttling,
+// and decrypts its content and issues an unlock session if correct. On failure, it responds with an error and returns false.
+func (api *API) verifyUnlockPassword(w http.ResponseWriter, r *http.Request, slug string, shortlink *entities.Shortlink, password string) bool {
+	if retryAfter := api.reserveUnlockAttempt(slug, r); retryAfter > 0 {
+		countResolve(shortlink.Kind, resolveThrottled)
+		api.UnlockThrottled(w, retryAfter)
+		return false
+	}
+
+	if !api.ShortlinkService.VerifyPassword(slug, shortlink, password) {
+		api.recordUnlockFailure(slug, r)
+		countResolve(shortlink.Kind, resolveUnauthorized)
+		api.Unauthorized(errors.ErrPasswordInvalid, w)
+		return false
+	}
+
+	api.releaseUnlockAttempt(slug, r)
+
+	contentKey, err := api.ShortlinkService.ContentKeyFromPassword(shortlink, password)
+	if err != nil {
+		api.InternalServerError(err, w)
+		return false
+	}
+
+	if err := api.ShortlinkService.DecryptContent(shortlink, contentKey); err != nil {
+		api.InternalServerError(err, w)
+		return false
+	}
+
+	api.issueUnlockSession(w, slug, shortlink, contentKey)
+
+	api.Logger.Info("password verified", log.Str("slug", slug))
+
+	return true
+}
+
+func unlockGuardKeys(slug string, r *http.Request) []string {
+	return []string{"slug:" + slug, "ip:" + realip.FromRequest(r)}
+}
+
+// StartUnlockGuardSweep clears out, once per interval until the context is cancelled, failed password
+// attempts older than the lockout duration, so that the unlock guard does not grow without bound.
+func (api *API) StartUnlockGuardSweep(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			api.forgetStaleUnlockAttempts(time.Now())
+		}
+	}
+}
+
+// forgetStaleUnlockAttempts clears out failed attempts older than the lockout duration.
+func (api *API) forgetStaleUnlockAttempts(now time.Time) {
+	api.unlockGuard.mutex.Lock()
+	defer api.unlockGuard.mutex.Unlock()
+
+	for key, attempts := range api.unlockGuard.attempts {
+		if api.isStale(attempts, now) {
+			delete(api.unlockGuard.attempts, key)
+		}
+	}
+}
+
+func (api *API) isStale(attempts *failedAttempts, now time.Time) bool {
+	return now.After(attempts.blockedUntil) && now.Sub(attempts.lastFailure) > api.Config.UnlockGuard.LockoutDuration
+}
+
+// reserveUnlockAttempt checks if a password attempt on a slug by a client is allowed and, if so, counts it
+// as failed right away under the same lock, so that parallel attempts cannot all pass the check before
+// any of them is counted. Past the free attempts, further attempts are blocked with exponential backoff,
+// and past the lockout threshold, for the lockout duration. Returns how long until an attempt is allowed,
+// or zero if this one is. A correct password releases the attempt with releaseUnlockAttempt.
+func (api *API) reserveUnlockAttempt(slug string, r *http.Request) time.Duration {
+	if !api.Config.UnlockGuard.Enabled {
+		return 0
+	}
+
+	now := time.Now()
+	keys := unlockGuardKeys(slug, r)
+
+	api.unlockGuard.mutex.Lock()
+	defer api.unlockGuard.mutex.Unlock()
+
+	if api.unlockGuard.attempts == nil {
+		api.unlockGuard.attempts = make(map[string]*failedAttempts)
+	}
+
+	var retryAfter time.Duration
+
+	for _, key := range keys {
+		if attempts, ok := api.unlockGuard.attempts[key]; ok {
+			retryAfter = max(retryAfter, attempts.blockedUntil.Sub(now))
+		}
+	}
+
+	if retryAfter > 0 {
+		return retryAfter
+	}
+
+	for _, key := range keys {
+		attempts, ok := api.unlockGuard.attempts[key]
+		if !ok || api.isStale(attempts, now) {
+			attempts = &failedAttempts{}
+			api.unlockGuard.attempts[key] = attempts
+		}
+
+		attempts.count++
+		attempts.lastFailure = now
+
+		if delay := api.unlockBlockDuration(attempts.count); delay > 0 {
+			attempts.blockedUntil = now.Add(delay)
+		}
+	}
+
+	return 0
+}
+
+// unlockBlockDuration returns how long to block password attempts after a number of failed ones.
+func (api *API) unlockBlockDuration(count int) time.Duration {
+	cfg := api.Config.UnlockGuard
+
+	switch {
+	case count >= cfg.LockoutThreshold:
+		return cfg.LockoutDuration
+	case count > cfg.FreeAttempts:
+		exponent := min(count-cfg.FreeAttempts-1, 30) // prevent overflow
+		return min(cfg.BaseDelay*time.Duration(1<<exponent), cfg.LockoutDuration)
+	default:
+		return 0
+	}
+}
+
+// recordUnlockFailure counts a failed password attempt in metrics, already counted by
+// reserveUnlockAttempt, and logs a lockout if the attempt reached the lockout threshold.
+func (api *API) recordUnlockFailure(slug string, r *http.Request) {
+	passwordFailures.Inc()
+
+	if !api.Config.UnlockGuard.Enabled {
+		return
+	}
+
+	api.unlockGuard.mutex.Lock()
+	defer api.unlockGuard.mutex.Unlock()
+
+	for _, key := range unlockGuardKeys(slug, r) {
+		attempts, ok := api.unlockGuard.attempts[key]
+		if !ok || attempts.count != api.Config.UnlockGuard.LockoutThreshold {
+			continue
+		}
+
+		api.Logger.Info(
+			"locked out password attempts on protected shortlink",
+			log.Str("key", key),
+			log.Int("failed_attempts", attempts.count),
+			log.Str("locked_until", attempts.blockedUntil.UTC().Format(time.RFC3339)),
+		)
+	}
+}
+
+// releaseUnlockAttempt uncounts a password attempt reserved on a slug that turned out correct, shortening
+// the slug's block to that of its remaining failed attempts, and forgets the failed attempts of the client.
+// Those on the slug are kept, so that a client guessing the password cannot reset them by unlocking another slug.
+func (api *API) releaseUnlockAttempt(slug string, r *http.Request) {
+	if !api.Config.UnlockGuard.Enabled {
+		return
+	}
+
+	api.unlockGuard.mutex.Lock()
+	defer api.unlockGuard.mutex.Unlock()
+
+	delete(api.unlockGuard.attempts, "ip:"+realip.FromRequest(r))
+
+	if attempts, ok := api.unlockGuard.attempts["slug:"+slug]; ok {
+		attempts.count = max(attempts.count-1, 0)
+
+		if blockedUntil := attempts.lastFailure.Add(api.unlockBlockDuration(attempts.count)); blockedUntil.Before(attempts.blockedUntil) {
+			attempts.blockedUntil = blockedUntil
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ivov/n8n-shortlink/internal/db/entities"
+	"github.com/ivov/n8n-shortlink/internal/services"
+)
+
+const (
+	unlockCookieName        = "n8n_shortlink_unlock"
+	unlockSessionKeyPurpose = "unlock-session"
+)
+
+// signUnlockSession signs a slug's unlock expiry and sealed content key, if any. The password hash
+// is signed along, so that changing the password revokes all sessions for the slug.
+func (api *API) signUnlockSession(slug string, shortlink *entities.Shortlink, expiry int64, sealedKey string) string {
+	message := slug + "|" + strconv.FormatInt(expiry, 10) + "|" + shortlink.Password
+	if sealedKey != "" {
+		message += "|" + sealedKey
+	}
+
+	mac := hmac.New(sha256.New, []byte(api.Config.UnlockSession.Secret))
+	mac.Write([]byte(message))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// issueUnlockSession sets an HttpOnly cookie scoped to a slug, keeping it unlocked for the session TTL.
+// The cookie carries the content key of an encrypted shortlink, sealed with the session secret.
+func (api *API) issueUnlockSession(w http.ResponseWriter, slug string, shortlink *entities.Shortlink, contentKey []byte) {
+	if api.Config.UnlockSession.Secret == "" {
+		return
+	}
+
+	sealedKey, err := services.SealContentKey(unlockSessionKeyPurpose, api.Config.UnlockSession.Secret, contentKey)
+	if err != nil {
+		api.Logger.Error(err) // visitor will be challenged again
+		return
+	}
+
+	expiry := time.Now().Add(api.Config.UnlockSession.TTL).Unix()
+
+	value := strconv.FormatInt(expiry, 10) + "." + api.signUnlockSession(slug, shortlink, expiry, sealedKey)
+	if sealedKey != "" {
+		value += "." + sealedKey
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     unlockCookieName,
+		Value:    value,
+		Path:     "/" + slug,
+		MaxAge:   int(api.Config.UnlockSession.TTL.Seconds()),
+		HttpOnly: true,
+		Secure:   api.Config.Env == "production",
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// hasUnlockSession checks if a request carries an unexpired unlock cookie signed for a slug and its current password,
+// and returns the content key it carries, which is nil for unencrypted shortlinks.
+func (api *API) hasUnlockSession(r *http.Request, slug string, shortlink *entities.Shortlink) ([]byte, bool) {
+	if api.Config.UnlockSession.Secret == "" {
+		return nil, false
+	}
+
+	cookie, err := r.Cookie(unlockCookieName)
+	if err != nil {
+		return nil, false
+	}
+
+	parts := strings.SplitN(cookie.Value, ".", 3) // expiry, signature, optional sealed content key
+	if len(parts) < 2 {
+		return nil, false
+	}
+
+	expiry, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || time.Now().Unix() >= expiry {
+		return nil, false
+	}
+
+	sealedKey := ""
+	if len(parts) == 3 {
+		sealedKey = parts[2]
+	}
+
+	if !hmac.Equal([]byte(parts[1]), []byte(api.signUnlockSession(slug, shortlink, expiry, sealedKey))) {
+		return nil, false
+	}
+
+	contentKey, err := services.OpenContentKey(unlockSessionKeyPurpose, api.Config.UnlockSession.Secret, sealedKey)
+	if err != nil {
+		return nil, false
+	}
+
+	return contentKey, true
+}
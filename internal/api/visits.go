@@ -0,0 +1,27 @@
+package api
+
+import (
+	stdErrors "errors"
+	"net/http"
+
+	"github.com/ivov/n8n-shortlink/internal/db/entities"
+	"github.com/ivov/n8n-shortlink/internal/errors"
+	"github.com/tomasen/realip"
+)
+
+// saveVisit counts a successful resolve and records a visit by a request to a shortlink of a kind,
+// logging and moving on if it fails so that a visit never blocks access to a shortlink.
+func (api *API) saveVisit(r *http.Request, visit entities.Visit, kind string) {
+	countResolve(kind, resolveOK)
+
+	err := api.VisitService.SaveVisit(visit, kind, realip.FromRequest(r))
+	if err == nil {
+		return
+	}
+
+	if stdErrors.Is(err, errors.ErrVisitQueueFull) {
+		droppedVisits.Inc()
+	}
+
+	api.Logger.Error(err)
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"flag"
+	"fmt"
 	"os"
 	"path/filepath"
 	"time"
@@ -29,8 +30,68 @@ type Config struct {
 	Sentry struct {
 		DSN string
 	}
-	MetadataMode *bool // whether to display binary metadata and exit
-	Build        struct {
+	Policy struct {
+		FilePath       string
+		ReloadInterval time.Duration
+	}
+	Resolver struct {
+		Enabled bool
+		MaxHops int
+		Timeout time.Duration
+	}
+	HealthCheck struct {
+		Enabled     bool
+		Interval    time.Duration
+		Timeout     time.Duration
+		Concurrency int
+	}
+	Argon2 struct {
+		MemoryKiB   int
+		Iterations  int
+		Parallelism int
+	}
+	UnlockGuard struct {
+		Enabled          bool
+		FreeAttempts     int           // failed password attempts allowed before backoff
+		BaseDelay        time.Duration // backoff after the first attempt past the free ones, doubled on each further one
+		LockoutThreshold int           // failed password attempts after which to lock out
+		LockoutDuration  time.Duration
+	}
+	UnlockSession struct {
+		Secret string // key to sign unlock cookies with, random on every start if empty
+		TTL    time.Duration
+	}
+	Redirect struct {
+		DefaultType int // HTTP status code for URL shortlinks created without a redirect type
+	}
+	Encryption struct {
+		Keys        string // comma-separated `<id>:<base64 key>` master keys, the first one active
+		KeyFilePath string // file with one `<id>:<base64 key>` master key per line, after the keys above
+	}
+	VisitQueue struct {
+		Enabled       bool
+		Size          int
+		BatchSize     int
+		FlushInterval time.Duration
+	}
+	VisitRetention struct {
+		Enabled   bool
+		Days      int           // age in days after which visits are rolled up and pruned
+		Interval  time.Duration // between pruning runs
+		ChunkSize int           // max visits deleted per transaction
+	}
+	Admin struct {
+		Token string // bearer token for admin endpoints, disabled if empty
+	}
+	Privacy struct {
+		HashCreatorIP bool
+	}
+	Metrics struct {
+		RefreshInterval time.Duration // between refreshes of costly gauges, i.e. stored and broken shortlinks
+	}
+	MetadataMode  *bool // whether to display binary metadata and exit
+	ReencryptMode *bool // whether to re-encrypt all shortlink content with the active key and exit
+	Build         struct {
 		CommitSha string
 	}
 }
@@ -89,6 +150,242 @@ func NewConfig(commitSha string) Config {
 		"Duration after which inactive rate limiter clients are cleared",
 	)
 
+	home, err := os.UserHomeDir()
+	if err != nil {
+		panic(err)
+	}
+
+	flag.StringVar(
+		&config.Policy.FilePath,
+		"policy-file-path",
+		env.GetStr("N8N_SHORTLINK_POLICY_FILE_PATH", filepath.Join(home, ".n8n-shortlink", "url-policy.json")),
+		"Path to JSON file with URL policy rules, created with default rules if missing",
+	)
+
+	flag.DurationVar(
+		&config.Policy.ReloadInterval,
+		"policy-reload-interval",
+		env.GetDuration("N8N_SHORTLINK_POLICY_RELOAD_INTERVAL", "10s"),
+		"Duration between checks of the URL policy file for changes",
+	)
+
+	flag.BoolVar(
+		&config.Resolver.Enabled,
+		"resolver-enabled",
+		env.GetBool("N8N_SHORTLINK_RESOLVER_ENABLED", true),
+		"Whether to follow redirects of URLs at creation to check their final destinations",
+	)
+
+	flag.IntVar(
+		&config.Resolver.MaxHops,
+		"resolver-max-hops",
+		env.GetInt("N8N_SHORTLINK_RESOLVER_MAX_HOPS", 5),
+		"Max number of redirects to follow when resolving a URL",
+	)
+
+	flag.DurationVar(
+		&config.Resolver.Timeout,
+		"resolver-timeout",
+		env.GetDuration("N8N_SHORTLINK_RESOLVER_TIMEOUT", "5s"),
+		"Max duration of resolving the redirects of a URL",
+	)
+
+	flag.BoolVar(
+		&config.HealthCheck.Enabled,
+		"health-check-enabled",
+		env.GetBool("N8N_SHORTLINK_HEALTH_CHECK_ENABLED", true),
+		"Whether to periodically check the destinations of URL shortlinks",
+	)
+
+	flag.DurationVar(
+		&config.HealthCheck.Interval,
+		"health-check-interval",
+		env.GetDuration("N8N_SHORTLINK_HEALTH_CHECK_INTERVAL", "6h"),
+		"Duration between checks of the destinations of URL shortlinks",
+	)
+
+	flag.DurationVar(
+		&config.HealthCheck.Timeout,
+		"health-check-timeout",
+		env.GetDuration("N8N_SHORTLINK_HEALTH_CHECK_TIMEOUT", "10s"),
+		"Max duration of a single destination check",
+	)
+
+	flag.IntVar(
+		&config.HealthCheck.Concurrency,
+		"health-check-concurrency",
+		env.GetInt("N8N_SHORTLINK_HEALTH_CHECK_CONCURRENCY", 8),
+		"Max number of destinations checked at the same time",
+	)
+
+	flag.IntVar(
+		&config.Argon2.MemoryKiB,
+		"argon2-memory-kib",
+		env.GetInt("N8N_SHORTLINK_ARGON2_MEMORY_KIB", 19456),
+		"Memory in KiB used by argon2id to hash shortlink passwords",
+	)
+
+	flag.IntVar(
+		&config.Argon2.Iterations,
+		"argon2-iterations",
+		env.GetInt("N8N_SHORTLINK_ARGON2_ITERATIONS", 2),
+		"Number of passes over memory by argon2id to hash shortlink passwords",
+	)
+
+	flag.IntVar(
+		&config.Argon2.Parallelism,
+		"argon2-parallelism",
+		env.GetInt("N8N_SHORTLINK_ARGON2_PARALLELISM", 1),
+		"Number of threads used by argon2id to hash shortlink passwords",
+	)
+
+	flag.BoolVar(
+		&config.UnlockGuard.Enabled,
+		"unlock-guard-enabled",
+		env.GetBool("N8N_SHORTLINK_UNLOCK_GUARD_ENABLED", true),
+		"Whether to throttle failed password attempts on protected shortlinks per slug and per client",
+	)
+
+	flag.IntVar(
+		&config.UnlockGuard.FreeAttempts,
+		"unlock-guard-free-attempts",
+		env.GetInt("N8N_SHORTLINK_UNLOCK_GUARD_FREE_ATTEMPTS", 3),
+		"Failed password attempts allowed before backoff",
+	)
+
+	flag.DurationVar(
+		&config.UnlockGuard.BaseDelay,
+		"unlock-guard-base-delay",
+		env.GetDuration("N8N_SHORTLINK_UNLOCK_GUARD_BASE_DELAY", "1s"),
+		"Backoff after the first failed password attempt past the free ones, doubled on each further one",
+	)
+
+	flag.IntVar(
+		&config.UnlockGuard.LockoutThreshold,
+		"unlock-guard-lockout-threshold",
+		env.GetInt("N8N_SHORTLINK_UNLOCK_GUARD_LOCKOUT_THRESHOLD", 10),
+		"Failed password attempts after which the slug or client is locked out",
+	)
+
+	flag.DurationVar(
+		&config.UnlockGuard.LockoutDuration,
+		"unlock-guard-lockout-duration",
+		env.GetDuration("N8N_SHORTLINK_UNLOCK_GUARD_LOCKOUT_DURATION", "15m"),
+		"Duration of a lockout, also after which failed password attempts are forgotten",
+	)
+
+	flag.StringVar(
+		&config.UnlockSession.Secret,
+		"unlock-session-secret",
+		env.GetStr("N8N_SHORTLINK_UNLOCK_SESSION_SECRET", ""),
+		"Secret to sign unlock cookies for protected shortlinks with, random on every start if empty",
+	)
+
+	flag.DurationVar(
+		&config.UnlockSession.TTL,
+		"unlock-session-ttl",
+		env.GetDuration("N8N_SHORTLINK_UNLOCK_SESSION_TTL", "1h"),
+		"Duration for which a protected shortlink stays unlocked after a successful password check",
+	)
+
+	flag.IntVar(
+		&config.Redirect.DefaultType,
+		"redirect-default-type",
+		env.GetInt("N8N_SHORTLINK_REDIRECT_DEFAULT_TYPE", 301),
+		"Default HTTP status code for URL shortlink redirects (301, 302, 307, 308)",
+	)
+
+	flag.StringVar(
+		&config.Encryption.Keys,
+		"encryption-keys",
+		env.GetStr("N8N_SHORTLINK_ENCRYPTION_KEYS", ""),
+		"Comma-separated master keys as <id>:<base64 key> to encrypt shortlink content at rest with, the first one active",
+	)
+
+	flag.StringVar(
+		&config.Encryption.KeyFilePath,
+		"encryption-key-file-path",
+		env.GetStr("N8N_SHORTLINK_ENCRYPTION_KEY_FILE_PATH", ""),
+		"Path to file with one master key as <id>:<base64 key> per line, after any keys in N8N_SHORTLINK_ENCRYPTION_KEYS",
+	)
+
+	flag.BoolVar(
+		&config.VisitQueue.Enabled,
+		"visit-queue-enabled",
+		env.GetBool("N8N_SHORTLINK_VISIT_QUEUE_ENABLED", true),
+		"Whether to queue visits and write them in batches instead of on the request path",
+	)
+
+	flag.IntVar(
+		&config.VisitQueue.Size,
+		"visit-queue-size",
+		env.GetInt("N8N_SHORTLINK_VISIT_QUEUE_SIZE", 10000),
+		"Max number of queued visits, after which further visits are dropped",
+	)
+
+	flag.IntVar(
+		&config.VisitQueue.BatchSize,
+		"visit-queue-batch-size",
+		env.GetInt("N8N_SHORTLINK_VISIT_QUEUE_BATCH_SIZE", 100),
+		"Max number of queued visits written in a single transaction",
+	)
+
+	flag.DurationVar(
+		&config.VisitQueue.FlushInterval,
+		"visit-queue-flush-interval",
+		env.GetDuration("N8N_SHORTLINK_VISIT_QUEUE_FLUSH_INTERVAL", "1s"),
+		"Max duration a queued visit waits to be written",
+	)
+
+	flag.BoolVar(
+		&config.VisitRetention.Enabled,
+		"visit-retention-enabled",
+		env.GetBool("N8N_SHORTLINK_VISIT_RETENTION_ENABLED", true),
+		"Whether to periodically roll up visits past retention into daily rollups and prune them",
+	)
+
+	flag.IntVar(
+		&config.VisitRetention.Days,
+		"visit-retention-days",
+		env.GetInt("N8N_SHORTLINK_VISIT_RETENTION_DAYS", 90),
+		"Age in days after which visits are rolled up into daily rollups and pruned",
+	)
+
+	flag.DurationVar(
+		&config.VisitRetention.Interval,
+		"visit-retention-interval",
+		env.GetDuration("N8N_SHORTLINK_VISIT_RETENTION_INTERVAL", "1h"),
+		"Duration between runs of rolling up and pruning visits past retention",
+	)
+
+	flag.IntVar(
+		&config.VisitRetention.ChunkSize,
+		"visit-retention-chunk-size",
+		env.GetInt("N8N_SHORTLINK_VISIT_RETENTION_CHUNK_SIZE", 1000),
+		"Max number of visits deleted in a single transaction when pruning",
+	)
+
+	flag.StringVar(
+		&config.Admin.Token,
+		"admin-token",
+		env.GetStr("N8N_SHORTLINK_ADMIN_TOKEN", ""),
+		"Bearer token for admin endpoints, e.g. exporting all shortlinks, which are disabled if empty",
+	)
+
+	flag.BoolVar(
+		&config.Privacy.HashCreatorIP,
+		"hash-creator-ip",
+		env.GetBool("N8N_SHORTLINK_HASH_CREATOR_IP", false),
+		"Whether to store creator IPs as HMACs keyed with a daily-rotating salt instead of in plaintext",
+	)
+
+	flag.DurationVar(
+		&config.Metrics.RefreshInterval,
+		"metrics-refresh-interval",
+		env.GetDuration("N8N_SHORTLINK_METRICS_REFRESH_INTERVAL", "1m"),
+		"Duration between refreshes of the metrics of stored shortlinks by kind and broken shortlinks",
+	)
+
 	const defaultSentryDSN = "https://f53e747195fcd00533f1f118ce69b44f@o4504685792460800.ingest.us.sentry.io/4507658952638464"
 
 	flag.StringVar(
@@ -99,9 +396,41 @@ func NewConfig(commitSha string) Config {
 	)
 
 	config.MetadataMode = flag.Bool("metadata-mode", false, "Display binary metadata and exit")
+	config.ReencryptMode = flag.Bool("reencrypt-mode", false, "Re-encrypt all shortlink content with the active master key and exit")
 
 	flag.Parse()
 
+	switch config.Redirect.DefaultType {
+	case 301, 302, 307, 308:
+	default:
+		panic(fmt.Errorf("unsupported default redirect type %d", config.Redirect.DefaultType))
+	}
+
+	if config.Argon2.Iterations < 1 || config.Argon2.Parallelism < 1 || config.Argon2.Parallelism > 255 ||
+		config.Argon2.MemoryKiB < 8*config.Argon2.Parallelism {
+		panic(fmt.Errorf("unsupported argon2 params m=%d,t=%d,p=%d", config.Argon2.MemoryKiB, config.Argon2.Iterations, config.Argon2.Parallelism))
+	}
+
+	if config.Policy.ReloadInterval <= 0 {
+		panic(fmt.Errorf("unsupported policy reload interval %s", config.Policy.ReloadInterval))
+	}
+
+	if config.HealthCheck.Interval <= 0 {
+		panic(fmt.Errorf("unsupported health check interval %s", config.HealthCheck.Interval))
+	}
+
+	if config.VisitQueue.Size < 1 || config.VisitQueue.BatchSize < 1 || config.VisitQueue.FlushInterval <= 0 {
+		panic(fmt.Errorf("unsupported visit queue params size=%d,batch_size=%d,flush_interval=%s", config.VisitQueue.Size, config.VisitQueue.BatchSize, config.VisitQueue.FlushInterval))
+	}
+
+	if config.VisitRetention.Days < 1 || config.VisitRetention.ChunkSize < 1 || config.VisitRetention.Interval <= 0 {
+		panic(fmt.Errorf("unsupported visit retention params days=%d,chunk_size=%d,interval=%s", config.VisitRetention.Days, config.VisitRetention.ChunkSize, config.VisitRetention.Interval))
+	}
+
+	if config.Metrics.RefreshInterval <= 0 {
+		panic(fmt.Errorf("unsupported metrics refresh interval %s", config.Metrics.RefreshInterval))
+	}
+
 	return config
 }
 
@@ -0,0 +1,36 @@
+package entities
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// scanJSONColumn converts a sqlite TEXT holding JSON into a value of a type named in errors,
+// or into the type's zero value if NULL.
+func scanJSONColumn[T any](dest *T, typeName string, value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		var zero T
+		*dest = zero
+	case string:
+		if err := json.Unmarshal([]byte(v), dest); err != nil {
+			return fmt.Errorf("parsing JSON for %s: %w", typeName, err)
+		}
+	default:
+		return fmt.Errorf("unsupported scan type for %s: %T", typeName, v)
+	}
+	return nil
+}
+
+// jsonColumnValue converts a value of a length into a sqlite TEXT holding JSON, or NULL if empty.
+func jsonColumnValue(value any, length int) (driver.Value, error) {
+	if length == 0 {
+		return nil, nil
+	}
+	bytes, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return string(bytes), nil
+}
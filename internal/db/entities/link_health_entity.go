@@ -0,0 +1,10 @@
+package entities
+
+// LinkHealth represents the result of the latest check of a URL shortlink's destination.
+type LinkHealth struct {
+	Slug       string     `json:"-" db:"slug"`
+	StatusCode *int       `json:"status_code" db:"status_code"` // nil if the destination could not be reached
+	IsBroken   bool       `json:"is_broken" db:"is_broken"`
+	Error      *string    `json:"error,omitempty" db:"error"`
+	CheckedAt  CustomTime `json:"checked_at" db:"checked_at"`
+}
@@ -0,0 +1,24 @@
+package entities
+
+import "database/sql/driver"
+
+// RoutingRule sends visitors of a URL shortlink who match all its conditions to a destination.
+type RoutingRule struct {
+	Languages   []string `json:"languages,omitempty"`    // optional, e.g. "de" or "pt-BR", matched against preferred language
+	Device      string   `json:"device,omitempty"`       // optional, 'mobile' or 'desktop'
+	RefererHost string   `json:"referer_host,omitempty"` // optional, matched incl. subdomains
+	Destination string   `json:"destination"`            // required, URL
+}
+
+// RoutingRules is an ordered list of routing rules, where the first match wins.
+type RoutingRules []RoutingRule
+
+// Scan converts a sqlite TEXT JSON array into RoutingRules.
+func (rr *RoutingRules) Scan(value interface{}) error {
+	return scanJSONColumn(rr, "RoutingRules", value)
+}
+
+// Value converts RoutingRules into a sqlite TEXT JSON array, or NULL if empty.
+func (rr RoutingRules) Value() (driver.Value, error) {
+	return jsonColumnValue(rr, len(rr))
+}
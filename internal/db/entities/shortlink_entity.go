@@ -8,14 +8,30 @@ import (
 
 // Shortlink represents a shortlink to a workflow JSON or URL.
 type Shortlink struct {
-	Slug          string      `json:"slug,omitempty" db:"slug"`                     // added by API
-	Kind          string      `json:"kind" db:"kind"`                               // required, 'workflow' or 'url'
-	Content       string      `json:"content" db:"content"`                         // required, JSON or URL
-	CreatorIP     string      `json:"creator_ip,omitempty" db:"creator_ip"`         // added by API
-	CreatedAt     CustomTime  `json:"created_at,omitempty" db:"created_at"`         // added by DB
-	ExpiresAt     *CustomTime `json:"expires_at,omitempty" db:"expires_at"`         // optional
-	Password      string      `json:"password,omitempty" db:"password"`             // optional
-	AllowedVisits int         `json:"allowed_visits,omitempty" db:"allowed_visits"` // optional, -1 for unlimited
+	Slug                string       `json:"slug,omitempty" db:"slug"`                       // added by API
+	Kind                string       `json:"kind" db:"kind"`                                 // 'workflow' or 'url' detected by API, or 'encrypted' if set
+	Content             string       `json:"content" db:"content"`                           // required, JSON, URL or encrypted blob
+	CreatorIP           string       `json:"creator_ip,omitempty" db:"creator_ip"`           // added by API
+	CreatedAt           CustomTime   `json:"created_at,omitempty" db:"created_at"`           // added by DB
+	ExpiresAt           *CustomTime  `json:"expires_at,omitempty" db:"expires_at"`           // optional
+	ActivateAt          *CustomTime  `json:"activate_at,omitempty" db:"activate_at"`         // optional, content unavailable until then
+	Password            string       `json:"password,omitempty" db:"password"`               // optional
+	AllowedVisits       int          `json:"allowed_visits,omitempty" db:"allowed_visits"`   // optional, -1 for unlimited
+	RedirectType        int          `json:"redirect_type,omitempty" db:"redirect_type"`     // optional, 301, 302, 307 or 308, only for 'url'
+	ForcePreview        bool         `json:"force_preview,omitempty" db:"force_preview"`     // optional, show preview on every visit, only for 'url'
+	ForwardQuery        bool         `json:"forward_query,omitempty" db:"forward_query"`     // optional, forward visitor query params, only for 'url'
+	UTMParams           StringMap    `json:"utm_params,omitempty" db:"utm_params"`           // optional, UTM params to append, only for 'url'
+	RedirectChain       StringList   `json:"-" db:"redirect_chain"`                          // added by API, URLs redirected through at creation, only for 'url'
+	RoutingRules        RoutingRules `json:"routing_rules,omitempty" db:"routing_rules"`     // optional, evaluated in order before falling back to content, only for 'url'
+	Variants            Variants     `json:"variants,omitempty" db:"variants"`               // optional, A/B split for visitors not matched by a routing rule, only for 'url'
+	StickyVariants      bool         `json:"sticky_variants,omitempty" db:"sticky_variants"` // optional, keep visitors on their variant via cookie, only for 'url'
+	ManagementToken     string       `json:"management_token,omitempty" db:"-"`              // added by API, returned only on creation
+	ManagementTokenHash string       `json:"-" db:"management_token_hash"`                   // added by API
+	ShareSecret         string       `json:"-" db:"share_secret"`                            // added by API, signs share tokens, only for protected
+	ContentKeys         StringMap    `json:"-" db:"content_keys"`                            // added by API, wrapped keys of encrypted content, only for protected
+	SealedRouting       string       `json:"-" db:"sealed_routing"`                          // added by API, routing rules and variants encrypted with content, only for protected
+	EncryptionKeyID     string       `json:"-" db:"encryption_key_id"`                       // added by API, ID of the master key content is encrypted at rest with
+	EncryptedDataKey    string       `json:"-" db:"encrypted_data_key"`                      // added by API, data key content is encrypted at rest with
 }
 
 // CustomTime handles timestamp conversion between Go's time.Time and sqlite's TEXT.
@@ -40,7 +56,33 @@ func (ct *CustomTime) Scan(value interface{}) error {
 	return nil
 }
 
-// Value converts a CustomTime into a sqlite TEXT timestamp
+// Value converts a CustomTime into a sqlite TEXT timestamp in UTC, like sqlite's CURRENT_TIMESTAMP.
 func (ct CustomTime) Value() (driver.Value, error) {
-	return ct.Time.Format(timeLayout), nil
+	return ct.Time.UTC().Format(timeLayout), nil
+}
+
+// StringMap handles conversion between a Go string map and a sqlite TEXT holding a JSON object.
+type StringMap map[string]string
+
+// Scan converts a sqlite TEXT JSON object into a StringMap.
+func (sm *StringMap) Scan(value interface{}) error {
+	return scanJSONColumn(sm, "StringMap", value)
+}
+
+// Value converts a StringMap into a sqlite TEXT JSON object, or NULL if empty.
+func (sm StringMap) Value() (driver.Value, error) {
+	return jsonColumnValue(sm, len(sm))
+}
+
+// StringList handles conversion between a Go string slice and a sqlite TEXT holding a JSON array.
+type StringList []string
+
+// Scan converts a sqlite TEXT JSON array into a StringList.
+func (sl *StringList) Scan(value interface{}) error {
+	return scanJSONColumn(sl, "StringList", value)
+}
+
+// Value converts a StringList into a sqlite TEXT JSON array, or NULL if empty.
+func (sl StringList) Value() (driver.Value, error) {
+	return jsonColumnValue(sl, len(sl))
 }
@@ -0,0 +1,15 @@
+package entities
+
+// ShortlinkExport is the metadata of a shortlink in an admin export, excluding its content and secrets.
+type ShortlinkExport struct {
+	Slug              string      `json:"slug" db:"slug"`
+	Kind              string      `json:"kind" db:"kind"`
+	CreatedAt         CustomTime  `json:"created_at" db:"created_at"`
+	ExpiresAt         *CustomTime `json:"expires_at" db:"expires_at"`
+	ActivateAt        *CustomTime `json:"activate_at" db:"activate_at"`
+	CreatorIP         string      `json:"creator_ip" db:"creator_ip"` // hashed if creator IP hashing was enabled on creation
+	RedirectType      int         `json:"redirect_type" db:"redirect_type"`
+	IsProtected       bool        `json:"is_protected" db:"is_protected"`
+	IsEncryptedAtRest bool        `json:"is_encrypted_at_rest" db:"is_encrypted_at_rest"`
+	Visits            int         `json:"visits" db:"visits"` // incl. visits rolled up past retention
+}
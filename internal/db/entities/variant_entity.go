@@ -0,0 +1,23 @@
+package entities
+
+import "database/sql/driver"
+
+// Variant is one of the destinations that visitors of a URL shortlink are split across.
+type Variant struct {
+	Name        string `json:"name"`        // required, unique per shortlink, recorded in visits
+	Destination string `json:"destination"` // required, URL
+	Weight      int    `json:"weight"`      // required, share of visitors relative to the other variants
+}
+
+// Variants is the list of destinations of an A/B split.
+type Variants []Variant
+
+// Scan converts a sqlite TEXT JSON array into Variants.
+func (v *Variants) Scan(value interface{}) error {
+	return scanJSONColumn(v, "Variants", value)
+}
+
+// Value converts Variants into a sqlite TEXT JSON array, or NULL if empty.
+func (v Variants) Value() (driver.Value, error) {
+	return jsonColumnValue(v, len(v))
+}
@@ -1,10 +1,30 @@
 package entities
 
+// Access paths of a visit to a shortlink.
+const (
+	// AccessPathRaw is a visit resolving to a redirect, workflow JSON or encrypted blob.
+	AccessPathRaw = "raw"
+	// AccessPathView is a visit to the canvas view of a workflow or the preview page of a URL.
+	AccessPathView = "view"
+	// AccessPathProtected is a visit to a password-protected shortlink, by password, unlock cookie or share token.
+	AccessPathProtected = "protected"
+)
+
 // Visit represents an access to a shortlink.
 type Visit struct {
-	ID        int        `json:"id" db:"id"`
-	Slug      string     `json:"slug" db:"slug"`
-	TS        CustomTime `json:"ts" db:"ts"`
-	Referer   string     `json:"referer" db:"referer"`
-	UserAgent string     `json:"user_agent" db:"user_agent"`
+	ID         int        `json:"id" db:"id"`
+	Slug       string     `json:"slug" db:"slug"`
+	TS         CustomTime `json:"ts" db:"ts"`
+	Referer    string     `json:"referer" db:"referer"`
+	UserAgent  string     `json:"user_agent" db:"user_agent"`
+	Variant    string     `json:"variant,omitempty" db:"variant"`         // empty if the shortlink has no A/B split
+	AccessPath string     `json:"access_path,omitempty" db:"access_path"` // empty for visits predating access paths
+	// classified from the user agent, empty for visits predating classification
+	Browser string `json:"browser,omitempty" db:"browser"`
+	OS      string `json:"os,omitempty" db:"os"`
+	Device  string `json:"device,omitempty" db:"device"`
+	IsBot   bool   `json:"is_bot" db:"is_bot"`
+	// VisitorHash is a daily-salted HMAC of the visitor's IP and user agent, to count unique visitors
+	// per day without storing IPs, empty for visits predating visitor hashes
+	VisitorHash string `json:"visitor_hash,omitempty" db:"visitor_hash"`
 }
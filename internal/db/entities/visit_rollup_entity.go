@@ -0,0 +1,21 @@
+package entities
+
+// VisitRollup summarizes the visits to a shortlink on a day, replacing them once past retention.
+type VisitRollup struct {
+	Slug            string `db:"slug"`
+	Day             string `db:"day"` // e.g. 2026-01-31
+	Count           int    `db:"count"`
+	BotCount        int    `db:"bot_count"`
+	Uniques         int    `db:"uniques"`     // by visitor hash
+	TopReferer      string `db:"top_referer"` // host, empty if no visit had a referer
+	TopRefererCount int    `db:"top_referer_count"`
+}
+
+// VisitVariantRollup summarizes the visits to a variant of a shortlink's A/B split on a day.
+type VisitVariantRollup struct {
+	Slug     string `db:"slug"`
+	Day      string `db:"day"`
+	Variant  string `db:"variant"`
+	Count    int    `db:"count"`
+	BotCount int    `db:"bot_count"`
+}
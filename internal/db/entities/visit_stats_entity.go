@@ -0,0 +1,26 @@
+package entities
+
+// VisitStats summarizes the visits to a shortlink over a range of days.
+type VisitStats struct {
+	Total         int            `json:"total"`           // all visits ever, excl. bots if excluded
+	From          string         `json:"from"`            // first day of the range, e.g. 2026-01-31
+	To            string         `json:"to"`              // last day of the range, inclusive
+	Daily         []DailyVisits  `json:"daily"`           // one entry per day in the range, incl. days without visits
+	TopReferers   []VisitCount   `json:"top_referers"`    // by referer host, excl. visits without referer, only the top one of rolled-up days
+	TopUserAgents []VisitCount   `json:"top_user_agents"` // by user agent family, e.g. "Chrome" or "Bot", excl. rolled-up days
+	AccessPaths   map[string]int `json:"access_paths"`    // by access path, "raw", "view" or "protected", excl. rolled-up days
+	Variants      map[string]int `json:"variants"`        // by A/B split variant name, excl. visits not split
+}
+
+// DailyVisits is the number of visits and unique visitors on a day.
+type DailyVisits struct {
+	Date    string `json:"date"`
+	Count   int    `json:"count"`
+	Uniques int    `json:"uniques"` // by visitor hash, excl. visits predating visitor hashes
+}
+
+// VisitCount is the number of visits sharing a value, e.g. a referer host.
+type VisitCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
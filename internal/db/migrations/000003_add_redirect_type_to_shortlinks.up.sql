@@ -0,0 +1 @@
+ALTER TABLE shortlinks ADD COLUMN redirect_type INTEGER NOT NULL DEFAULT 301 CHECK (redirect_type IN (301, 302, 307, 308));
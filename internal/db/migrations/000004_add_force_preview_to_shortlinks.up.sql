@@ -0,0 +1 @@
+ALTER TABLE shortlinks ADD COLUMN force_preview INTEGER NOT NULL DEFAULT 0 CHECK (force_preview IN (0, 1));
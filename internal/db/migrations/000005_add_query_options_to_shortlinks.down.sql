@@ -0,0 +1,3 @@
+ALTER TABLE shortlinks DROP COLUMN utm_params;
+
+ALTER TABLE shortlinks DROP COLUMN forward_query;
@@ -0,0 +1,3 @@
+ALTER TABLE shortlinks ADD COLUMN forward_query INTEGER NOT NULL DEFAULT 0 CHECK (forward_query IN (0, 1));
+
+ALTER TABLE shortlinks ADD COLUMN utm_params TEXT;
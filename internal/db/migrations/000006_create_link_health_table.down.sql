@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS link_health;
+
+DROP INDEX IF EXISTS idx_link_health_is_broken;
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS link_health (
+	slug TEXT PRIMARY KEY REFERENCES shortlinks(slug),
+	status_code INTEGER,
+	is_broken INTEGER NOT NULL CHECK (is_broken IN (0, 1)),
+	error TEXT,
+	checked_at TEXT DEFAULT CURRENT_TIMESTAMP
+) STRICT;
+
+CREATE INDEX IF NOT EXISTS idx_link_health_is_broken ON link_health(is_broken);
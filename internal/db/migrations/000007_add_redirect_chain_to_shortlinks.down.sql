@@ -0,0 +1 @@
+ALTER TABLE shortlinks DROP COLUMN redirect_chain;
@@ -0,0 +1 @@
+ALTER TABLE shortlinks ADD COLUMN redirect_chain TEXT;
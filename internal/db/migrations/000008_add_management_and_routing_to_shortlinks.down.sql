@@ -0,0 +1,3 @@
+ALTER TABLE shortlinks DROP COLUMN routing_rules;
+
+ALTER TABLE shortlinks DROP COLUMN management_token_hash;
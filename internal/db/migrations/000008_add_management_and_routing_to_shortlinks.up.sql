@@ -0,0 +1,3 @@
+ALTER TABLE shortlinks ADD COLUMN management_token_hash TEXT;
+
+ALTER TABLE shortlinks ADD COLUMN routing_rules TEXT;
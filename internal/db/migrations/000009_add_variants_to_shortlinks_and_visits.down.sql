@@ -0,0 +1,5 @@
+ALTER TABLE visits DROP COLUMN variant;
+
+ALTER TABLE shortlinks DROP COLUMN sticky_variants;
+
+ALTER TABLE shortlinks DROP COLUMN variants;
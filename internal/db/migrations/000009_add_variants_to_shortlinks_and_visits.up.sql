@@ -0,0 +1,5 @@
+ALTER TABLE shortlinks ADD COLUMN variants TEXT;
+
+ALTER TABLE shortlinks ADD COLUMN sticky_variants INTEGER NOT NULL DEFAULT 0 CHECK (sticky_variants IN (0, 1));
+
+ALTER TABLE visits ADD COLUMN variant TEXT NOT NULL DEFAULT '';
@@ -0,0 +1 @@
+ALTER TABLE shortlinks DROP COLUMN activate_at;
@@ -0,0 +1 @@
+ALTER TABLE shortlinks ADD COLUMN activate_at TEXT;
@@ -0,0 +1 @@
+ALTER TABLE shortlinks DROP COLUMN share_secret;
@@ -0,0 +1 @@
+ALTER TABLE shortlinks ADD COLUMN share_secret TEXT;
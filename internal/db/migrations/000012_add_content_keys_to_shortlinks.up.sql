@@ -0,0 +1 @@
+ALTER TABLE shortlinks ADD COLUMN content_keys TEXT;
@@ -0,0 +1,2 @@
+ALTER TABLE shortlinks DROP COLUMN encrypted_data_key;
+ALTER TABLE shortlinks DROP COLUMN encryption_key_id;
@@ -0,0 +1,2 @@
+ALTER TABLE shortlinks ADD COLUMN encryption_key_id TEXT;
+ALTER TABLE shortlinks ADD COLUMN encrypted_data_key TEXT;
@@ -0,0 +1,43 @@
+-- SQLite cannot alter a CHECK constraint, so the table is rebuilt. The rows are restored
+-- into the rebuilt table within the same transaction, so that foreign keys from visits and
+-- link_health are satisfied again by the time they are checked on commit.
+PRAGMA defer_foreign_keys = ON;
+
+CREATE TEMP TABLE shortlinks_backup AS SELECT * FROM shortlinks;
+
+DELETE FROM visits WHERE slug IN (SELECT slug FROM shortlinks_backup WHERE kind = 'encrypted');
+DELETE FROM link_health WHERE slug IN (SELECT slug FROM shortlinks_backup WHERE kind = 'encrypted');
+DELETE FROM shortlinks_backup WHERE kind = 'encrypted';
+
+DROP TABLE shortlinks;
+
+CREATE TABLE shortlinks (
+	slug TEXT PRIMARY KEY,
+	kind TEXT NOT NULL CHECK (kind IN ('workflow', 'url')),
+	content TEXT NOT NULL,
+	created_at TEXT DEFAULT CURRENT_TIMESTAMP,
+	creator_ip TEXT DEFAULT 'unknown',
+	expires_at TEXT,
+	password TEXT CHECK (LENGTH(password) = 0 OR LENGTH(password) >= 8),
+	allowed_visits INTEGER DEFAULT -1 CHECK (allowed_visits = -1 OR allowed_visits > 0),
+	redirect_type INTEGER NOT NULL DEFAULT 301 CHECK (redirect_type IN (301, 302, 307, 308)),
+	force_preview INTEGER NOT NULL DEFAULT 0 CHECK (force_preview IN (0, 1)),
+	forward_query INTEGER NOT NULL DEFAULT 0 CHECK (forward_query IN (0, 1)),
+	utm_params TEXT,
+	redirect_chain TEXT,
+	management_token_hash TEXT,
+	routing_rules TEXT,
+	variants TEXT,
+	sticky_variants INTEGER NOT NULL DEFAULT 0 CHECK (sticky_variants IN (0, 1)),
+	activate_at TEXT,
+	share_secret TEXT,
+	content_keys TEXT,
+	encryption_key_id TEXT,
+	encrypted_data_key TEXT
+) STRICT;
+
+INSERT INTO shortlinks (slug, kind, content, created_at, creator_ip, expires_at, password, allowed_visits, redirect_type, force_preview, forward_query, utm_params, redirect_chain, management_token_hash, routing_rules, variants, sticky_variants, activate_at, share_secret, content_keys, encryption_key_id, encrypted_data_key)
+SELECT slug, kind, content, created_at, creator_ip, expires_at, password, allowed_visits, redirect_type, force_preview, forward_query, utm_params, redirect_chain, management_token_hash, routing_rules, variants, sticky_variants, activate_at, share_secret, content_keys, encryption_key_id, encrypted_data_key
+FROM shortlinks_backup;
+
+DROP TABLE shortlinks_backup;
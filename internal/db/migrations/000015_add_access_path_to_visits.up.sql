@@ -0,0 +1 @@
+ALTER TABLE visits ADD COLUMN access_path TEXT NOT NULL DEFAULT '' CHECK (access_path IN ('', 'raw', 'view', 'protected'));
@@ -0,0 +1,4 @@
+ALTER TABLE visits DROP COLUMN is_bot;
+ALTER TABLE visits DROP COLUMN device;
+ALTER TABLE visits DROP COLUMN os;
+ALTER TABLE visits DROP COLUMN browser;
@@ -0,0 +1,4 @@
+ALTER TABLE visits ADD COLUMN browser TEXT NOT NULL DEFAULT '';
+ALTER TABLE visits ADD COLUMN os TEXT NOT NULL DEFAULT '';
+ALTER TABLE visits ADD COLUMN device TEXT NOT NULL DEFAULT '';
+ALTER TABLE visits ADD COLUMN is_bot INTEGER NOT NULL DEFAULT 0 CHECK (is_bot IN (0, 1));
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS visitor_salts;
+
+ALTER TABLE visits DROP COLUMN visitor_hash;
@@ -0,0 +1,6 @@
+ALTER TABLE visits ADD COLUMN visitor_hash TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS visitor_salts (
+	day TEXT PRIMARY KEY,
+	salt BLOB NOT NULL
+) STRICT;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_visits_ts;
+
+DROP TABLE IF EXISTS visit_daily_rollups;
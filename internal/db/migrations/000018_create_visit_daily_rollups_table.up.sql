@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS visit_daily_rollups (
+	slug TEXT NOT NULL REFERENCES shortlinks(slug),
+	day TEXT NOT NULL,
+	count INTEGER NOT NULL,
+	bot_count INTEGER NOT NULL,
+	uniques INTEGER NOT NULL,
+	top_referer TEXT NOT NULL DEFAULT '',
+	top_referer_count INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY (slug, day)
+) STRICT;
+
+CREATE INDEX IF NOT EXISTS idx_visits_ts ON visits(ts);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS visit_daily_variant_rollups;
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS visit_daily_variant_rollups (
+	slug TEXT NOT NULL REFERENCES shortlinks(slug),
+	day TEXT NOT NULL,
+	variant TEXT NOT NULL,
+	count INTEGER NOT NULL,
+	bot_count INTEGER NOT NULL,
+	PRIMARY KEY (slug, day, variant)
+) STRICT;
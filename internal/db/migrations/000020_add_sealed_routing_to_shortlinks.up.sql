@@ -0,0 +1 @@
+ALTER TABLE shortlinks ADD COLUMN sealed_routing TEXT;
@@ -31,7 +31,7 @@ var (
 	ErrPasswordInvalid = stdErrors.New("password is invalid")
 
 	// ErrKindUnsupported is returned when the shortlink kind is unsupported.
-	ErrKindUnsupported = stdErrors.New("shortlink kind is unsupported - neither \"url\" nor \"workflow\"")
+	ErrKindUnsupported = stdErrors.New("shortlink kind is unsupported - neither \"url\", \"workflow\" nor \"encrypted\"")
 
 	// ErrContentMalformed is returned when the content is malformed.
 	ErrContentMalformed = stdErrors.New("content is malformed - neither URL nor JSON")
@@ -44,22 +44,97 @@ var (
 
 	// ErrContentBlocked is returned when content contains suspicious patterns.
 	ErrContentBlocked = stdErrors.New("content blocked - suspicious pattern detected")
+
+	// ErrRedirectTypeUnsupported is returned when the redirect type is not a supported redirect status code.
+	ErrRedirectTypeUnsupported = stdErrors.New("redirect type is unsupported - must be 301, 302, 307 or 308")
+
+	// ErrRedirectChainTooLong is returned when a URL redirects through too many hops.
+	ErrRedirectChainTooLong = stdErrors.New("redirect chain is too long - URL redirects through too many hops")
+
+	// ErrRoutingRulesInvalid is returned when routing rules are malformed.
+	ErrRoutingRulesInvalid = stdErrors.New("routing rules are invalid")
+
+	// ErrVariantsInvalid is returned when A/B split variants are malformed.
+	ErrVariantsInvalid = stdErrors.New("variants are invalid")
+
+	// ErrManagementTokenInvalid is returned when the management token does not match the shortlink's.
+	ErrManagementTokenInvalid = stdErrors.New("management token is invalid")
+
+	// ErrPayloadMalformed is returned when the payload is not the expected JSON.
+	ErrPayloadMalformed = stdErrors.New("payload is malformed - must be a JSON object")
+
+	// ErrPasswordMissing is returned when a password is required but missing.
+	ErrPasswordMissing = stdErrors.New("password is missing")
+
+	// ErrShortlinkNotProtected is returned when an operation requires a password-protected shortlink.
+	ErrShortlinkNotProtected = stdErrors.New("shortlink is not password-protected")
+
+	// ErrShareTokenTTLInvalid is returned when the requested lifetime of a share token is out of range.
+	ErrShareTokenTTLInvalid = stdErrors.New("share token TTL is invalid - must be 1 to 2592000 seconds (30 days)")
+
+	// ErrEncryptedBlobInvalid is returned when the content of an encrypted shortlink is not a well-formed ciphertext blob.
+	ErrEncryptedBlobInvalid = stdErrors.New("encrypted blob is invalid - must be \"v1.\" followed by base64url-encoded IV and ciphertext, max 4 MB")
+
+	// ErrStatsRangeInvalid is returned when the requested range of days for stats is malformed or too long.
+	ErrStatsRangeInvalid = stdErrors.New("stats range is invalid - \"from\" and \"to\" must be YYYY-MM-DD, in order, at most 366 days apart")
+
+	// ErrExportRangeInvalid is returned when the requested range of days for an export is malformed.
+	ErrExportRangeInvalid = stdErrors.New("export range is invalid - \"from\" and \"to\" must be YYYY-MM-DD, in order")
+
+	// ErrAdminTokenInvalid is returned when the admin token does not match the configured one.
+	ErrAdminTokenInvalid = stdErrors.New("admin token is invalid")
+
+	// ErrUnlockThrottled is returned when too many failed password attempts were made on a protected shortlink.
+	ErrUnlockThrottled = stdErrors.New("too many failed password attempts - retry later")
+
+	// ErrVisitQueueFull is returned when a visit is dropped because the queue of visits to write is full.
+	ErrVisitQueueFull = stdErrors.New("visit queue is full - visit dropped")
+
+	// ErrUTMParamsInvalid is returned when UTM params contain unknown keys or empty values.
+	ErrUTMParamsInvalid = stdErrors.New("UTM params are invalid - keys must be utm_source, utm_medium, utm_campaign, utm_term or utm_content, with non-empty values")
 )
 
 // ToCode maps errors to error codes.
 var ToCode = map[error]string{
-	ErrShortlinkNotFound:   "SHORTLINK_NOT_FOUND",
-	ErrKindUnsupported:     "KIND_UNSUPPORTED",
-	ErrSlugTaken:           "SLUG_TAKEN",
-	ErrSlugMisformatted:    "SLUG_MISFORMATTED",
-	ErrSlugTooShort:        "SLUG_TOO_SHORT",
-	ErrSlugTooLong:         "SLUG_TOO_LONG",
-	ErrSlugReserved:        "SLUG_RESERVED",
-	ErrAuthHeaderMissing:   "AUTHORIZATION_HEADER_MISSING",
-	ErrAuthHeaderMalformed: "AUTHORIZATION_HEADER_MALFORMED",
-	ErrContentMalformed:    "CONTENT_MALFORMED",
-	ErrPasswordTooShort:    "PASSWORD_TOO_SHORT",
-	ErrPayloadTooLarge:     "PAYLOAD_TOO_LARGE",
-	ErrPasswordInvalid:     "PASSWORD_INVALID",
-	ErrContentBlocked:      "CONTENT_BLOCKED",
+	ErrShortlinkNotFound:       "SHORTLINK_NOT_FOUND",
+	ErrKindUnsupported:         "KIND_UNSUPPORTED",
+	ErrSlugTaken:               "SLUG_TAKEN",
+	ErrSlugMisformatted:        "SLUG_MISFORMATTED",
+	ErrSlugTooShort:            "SLUG_TOO_SHORT",
+	ErrSlugTooLong:             "SLUG_TOO_LONG",
+	ErrSlugReserved:            "SLUG_RESERVED",
+	ErrAuthHeaderMissing:       "AUTHORIZATION_HEADER_MISSING",
+	ErrAuthHeaderMalformed:     "AUTHORIZATION_HEADER_MALFORMED",
+	ErrContentMalformed:        "CONTENT_MALFORMED",
+	ErrPasswordTooShort:        "PASSWORD_TOO_SHORT",
+	ErrPayloadTooLarge:         "PAYLOAD_TOO_LARGE",
+	ErrPasswordInvalid:         "PASSWORD_INVALID",
+	ErrContentBlocked:          "CONTENT_BLOCKED",
+	ErrRedirectTypeUnsupported: "REDIRECT_TYPE_UNSUPPORTED",
+	ErrUTMParamsInvalid:        "UTM_PARAMS_INVALID",
+	ErrRedirectChainTooLong:    "REDIRECT_CHAIN_TOO_LONG",
+	ErrRoutingRulesInvalid:     "ROUTING_RULES_INVALID",
+	ErrManagementTokenInvalid:  "MANAGEMENT_TOKEN_INVALID",
+	ErrVariantsInvalid:         "VARIANTS_INVALID",
+	ErrUnlockThrottled:         "UNLOCK_THROTTLED",
+	ErrPayloadMalformed:        "PAYLOAD_MALFORMED",
+	ErrPasswordMissing:         "PASSWORD_MISSING",
+	ErrShortlinkNotProtected:   "SHORTLINK_NOT_PROTECTED",
+	ErrShareTokenTTLInvalid:    "SHARE_TOKEN_TTL_INVALID",
+	ErrEncryptedBlobInvalid:    "ENCRYPTED_BLOB_INVALID",
+	ErrStatsRangeInvalid:       "STATS_RANGE_INVALID",
+	ErrVisitQueueFull:          "VISIT_QUEUE_FULL",
+	ErrExportRangeInvalid:      "EXPORT_RANGE_INVALID",
+	ErrAdminTokenInvalid:       "ADMIN_TOKEN_INVALID",
+}
+
+// Code returns the error code of an error, or of the first error it wraps that has one.
+func Code(err error) string {
+	for ; err != nil; err = stdErrors.Unwrap(err) {
+		if code, ok := ToCode[err]; ok {
+			return code
+		}
+	}
+
+	return ""
 }
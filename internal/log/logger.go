@@ -98,6 +98,11 @@ func Int(key string, value int) zap.Field {
 	return zap.Int(key, value)
 }
 
+// Bool creates a zap.Field with a map having a bool value.
+func Bool(key string, value bool) zap.Field {
+	return zap.Bool(key, value)
+}
+
 // https://github.com/uber-go/zap/issues/661#issuecomment-520686037
 func utcTimeEncoder(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
 	enc.AppendString(t.UTC().Format("2006-01-02T15:04:05Z0700")) // e.g. 2019-08-13T04:39:11Z
@@ -136,6 +141,39 @@ func (l *Logger) ReportEnvs() {
 		"N8N_SHORTLINK_RATE_LIMITER_RPS",
 		"N8N_SHORTLINK_RATE_LIMITER_BURST",
 		"N8N_SHORTLINK_RATE_LIMITER_INACTIVITY",
+		"N8N_SHORTLINK_POLICY_FILE_PATH",
+		"N8N_SHORTLINK_POLICY_RELOAD_INTERVAL",
+		"N8N_SHORTLINK_RESOLVER_ENABLED",
+		"N8N_SHORTLINK_RESOLVER_MAX_HOPS",
+		"N8N_SHORTLINK_RESOLVER_TIMEOUT",
+		"N8N_SHORTLINK_HEALTH_CHECK_ENABLED",
+		"N8N_SHORTLINK_HEALTH_CHECK_INTERVAL",
+		"N8N_SHORTLINK_HEALTH_CHECK_TIMEOUT",
+		"N8N_SHORTLINK_HEALTH_CHECK_CONCURRENCY",
+		"N8N_SHORTLINK_ARGON2_MEMORY_KIB",
+		"N8N_SHORTLINK_ARGON2_ITERATIONS",
+		"N8N_SHORTLINK_ARGON2_PARALLELISM",
+		"N8N_SHORTLINK_UNLOCK_GUARD_ENABLED",
+		"N8N_SHORTLINK_UNLOCK_GUARD_FREE_ATTEMPTS",
+		"N8N_SHORTLINK_UNLOCK_GUARD_BASE_DELAY",
+		"N8N_SHORTLINK_UNLOCK_GUARD_LOCKOUT_THRESHOLD",
+		"N8N_SHORTLINK_UNLOCK_GUARD_LOCKOUT_DURATION",
+		"N8N_SHORTLINK_UNLOCK_SESSION_SECRET",
+		"N8N_SHORTLINK_UNLOCK_SESSION_TTL",
+		"N8N_SHORTLINK_REDIRECT_DEFAULT_TYPE",
+		"N8N_SHORTLINK_ENCRYPTION_KEYS",
+		"N8N_SHORTLINK_ENCRYPTION_KEY_FILE_PATH",
+		"N8N_SHORTLINK_VISIT_QUEUE_ENABLED",
+		"N8N_SHORTLINK_VISIT_QUEUE_SIZE",
+		"N8N_SHORTLINK_VISIT_QUEUE_BATCH_SIZE",
+		"N8N_SHORTLINK_VISIT_QUEUE_FLUSH_INTERVAL",
+		"N8N_SHORTLINK_VISIT_RETENTION_ENABLED",
+		"N8N_SHORTLINK_VISIT_RETENTION_DAYS",
+		"N8N_SHORTLINK_VISIT_RETENTION_INTERVAL",
+		"N8N_SHORTLINK_VISIT_RETENTION_CHUNK_SIZE",
+		"N8N_SHORTLINK_HASH_CREATOR_IP",
+		"N8N_SHORTLINK_METRICS_REFRESH_INTERVAL",
+		"N8N_SHORTLINK_ADMIN_TOKEN",
 	}
 
 	availableEnvs := []string{}
@@ -0,0 +1,274 @@
+package policy
+
+import (
+	"context"
+	_ "embed" // default rules
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ivov/n8n-shortlink/internal/errors"
+	"github.com/ivov/n8n-shortlink/internal/log"
+	"golang.org/x/net/publicsuffix"
+)
+
+//go:embed default_rules.json
+var defaultRules []byte
+
+// Rules are the operator-editable rules that URL shortlink destinations are evaluated against.
+type Rules struct {
+	// Schemes are the only URL schemes allowed.
+	Schemes []string `json:"schemes"`
+	// AllowHosts are hosts, including their subdomains, exempted from all deny rules.
+	AllowHosts []string `json:"allow_hosts"`
+	// DenyHosts are hosts, including their subdomains, that are blocked. A bare TLD blocks the whole TLD.
+	DenyHosts []string `json:"deny_hosts"`
+	// DenyIPHosts blocks URLs whose host is an IP address.
+	DenyIPHosts bool `json:"deny_ip_hosts"`
+	// DenyDomainKeywords are substrings blocked in the registrable domain or a subdomain label, e.g. "paypal-"
+	// in "paypal-secure.com" or "secure-paypal-login.example.com". A subdomain label that is just the keyword,
+	// e.g. "login" in "login.example.com", is not blocked.
+	DenyDomainKeywords []string `json:"deny_domain_keywords"`
+	// DenyPaths are named regexes blocked in the lowercased path.
+	DenyPaths []PathRule `json:"deny_paths"`
+}
+
+// PathRule is a named regex matched against the lowercased path of a URL.
+type PathRule struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+	regex   *regexp.Regexp
+}
+
+// Violation is returned when a URL matches a rule.
+type Violation struct {
+	Rule string // e.g. "deny_hosts:cpanel.site"
+}
+
+func (v *Violation) Error() string {
+	return fmt.Sprintf("%s - matched rule %q", errors.ErrContentBlocked, v.Rule)
+}
+
+func (v *Violation) Unwrap() error {
+	return errors.ErrContentBlocked
+}
+
+// Engine evaluates URLs against rules loaded from a file, reloading them when the file changes.
+type Engine struct {
+	Logger   *log.Logger
+	filePath string
+	mutex    sync.RWMutex
+	rules    *Rules
+	modTime  time.Time
+}
+
+// NewEngine creates an engine with rules loaded from a file, creating the file with the default
+// rules if it does not exist. With an empty file path, the default rules are used without a file.
+func NewEngine(filePath string, logger *log.Logger) (*Engine, error) {
+	engine := &Engine{Logger: logger, filePath: filePath}
+
+	if filePath == "" {
+		rules, err := parseRules(defaultRules)
+		if err != nil {
+			return nil, err
+		}
+		engine.rules = rules
+		return engine, nil
+	}
+
+	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+		if err := os.WriteFile(filePath, defaultRules, 0644); err != nil {
+			return nil, fmt.Errorf("failed to write default URL policy: %w", err)
+		}
+	}
+
+	if err := engine.Reload(); err != nil {
+		return nil, err
+	}
+
+	return engine, nil
+}
+
+// Reload reads and compiles the rules file, keeping the current rules if it is invalid.
+func (e *Engine) Reload() error {
+	info, err := os.Stat(e.filePath)
+	if err != nil {
+		return fmt.Errorf("failed to stat URL policy: %w", err)
+	}
+
+	bytes, err := os.ReadFile(e.filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read URL policy: %w", err)
+	}
+
+	rules, err := parseRules(bytes)
+	if err != nil {
+		return err
+	}
+
+	e.mutex.Lock()
+	e.rules = rules
+	e.modTime = info.ModTime()
+	e.mutex.Unlock()
+
+	return nil
+}
+
+// Watch reloads the rules file whenever it is modified, checking once per interval
+// until the context is cancelled.
+func (e *Engine) Watch(ctx context.Context, interval time.Duration) {
+	if e.filePath == "" {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(e.filePath)
+			if err != nil {
+				e.Logger.Error(err)
+				continue
+			}
+
+			e.mutex.RLock()
+			isModified := !info.ModTime().Equal(e.modTime)
+			e.mutex.RUnlock()
+
+			if !isModified {
+				continue
+			}
+
+			if err := e.Reload(); err != nil {
+				e.Logger.Error(err) // keep previous rules
+				continue
+			}
+
+			e.Logger.Info("reloaded URL policy", log.Str("file_path", e.filePath))
+		}
+	}
+}
+
+func parseRules(bytes []byte) (*Rules, error) {
+	var rules Rules
+
+	if err := json.Unmarshal(bytes, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse URL policy: %w", err)
+	}
+
+	// hosts are compared lowercased
+	for i, keyword := range rules.DenyDomainKeywords {
+		rules.DenyDomainKeywords[i] = strings.ToLower(keyword)
+	}
+
+	for i, rule := range rules.DenyPaths {
+		regex, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile URL policy path rule %q: %w", rule.Name, err)
+		}
+		rules.DenyPaths[i].regex = regex
+	}
+
+	return &rules, nil
+}
+
+// Evaluate checks a URL against the rules, returning a *Violation naming the matched rule if blocked.
+func (e *Engine) Evaluate(rawURL string) error {
+	e.mutex.RLock()
+	rules := e.rules
+	e.mutex.RUnlock()
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return &Violation{Rule: "malformed_url"}
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	if !slices.Contains(rules.Schemes, scheme) {
+		return &Violation{Rule: "schemes:" + scheme}
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "" {
+		return &Violation{Rule: "missing_host"}
+	}
+
+	for _, allowed := range rules.AllowHosts {
+		if matchesHost(host, allowed) {
+			return nil
+		}
+	}
+
+	for _, denied := range rules.DenyHosts {
+		if matchesHost(host, denied) {
+			return &Violation{Rule: "deny_hosts:" + denied}
+		}
+	}
+
+	if net.ParseIP(host) != nil {
+		if rules.DenyIPHosts {
+			return &Violation{Rule: "deny_ip_hosts"}
+		}
+	} else {
+		domain := registrableDomain(host)
+		subdomainLabels := strings.Split(strings.TrimSuffix(strings.TrimSuffix(host, domain), "."), ".")
+		for _, keyword := range rules.DenyDomainKeywords {
+			if matchesKeyword(domain, subdomainLabels, keyword) {
+				return &Violation{Rule: "deny_domain_keywords:" + keyword}
+			}
+		}
+	}
+
+	path := strings.ToLower(u.Path)
+	for _, rule := range rules.DenyPaths {
+		if rule.regex.MatchString(path) {
+			return &Violation{Rule: "deny_paths:" + rule.Name}
+		}
+	}
+
+	return nil
+}
+
+// matchesHost checks if a host is the rule host or one of its subdomains.
+func matchesHost(host, ruleHost string) bool {
+	ruleHost = strings.ToLower(ruleHost)
+	return host == ruleHost || strings.HasSuffix(host, "."+ruleHost)
+}
+
+// matchesKeyword checks if a keyword is in a registrable domain or in one of its subdomain labels,
+// skipping labels that are just the keyword, as in "login.example.com", which are common on legitimate sites.
+func matchesKeyword(domain string, subdomainLabels []string, keyword string) bool {
+	if strings.Contains(domain, keyword) {
+		return true
+	}
+
+	for _, label := range subdomainLabels {
+		if label != keyword && strings.Contains(label, keyword) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// registrableDomain returns the public suffix plus one label, e.g. "example.co.uk"
+// for "login.example.co.uk", or the host itself if it has none.
+func registrableDomain(host string) string {
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+
+	return domain
+}
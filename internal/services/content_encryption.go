@@ -0,0 +1,269 @@
+package services
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/ivov/n8n-shortlink/internal/db/entities"
+	"golang.org/x/crypto/argon2"
+)
+
+// Content of a protected shortlink is encrypted at rest with a random content key, which is stored
+// wrapped twice: by a key derived from the password, and by a key derived from the management token.
+// Neither the password nor the management token is stored, so the DB alone cannot decrypt the content.
+// Routing rules and variants are destinations too, so they are encrypted with the same content key.
+const (
+	contentKeyLength            = 32
+	contentKeyByPassword        = "password"
+	contentKeyByManagementToken = "management_token"
+)
+
+// IsContentEncrypted checks if a shortlink's content is encrypted at rest.
+func (ss *ShortlinkService) IsContentEncrypted(shortlink *entities.Shortlink) bool {
+	return len(shortlink.ContentKeys) > 0
+}
+
+// EncryptContent encrypts a shortlink's content, routing rules and variants in place with a new content key,
+// wrapped by the password and by the management token, which must be set on the shortlink. The redirect chain
+// is dropped, as it starts with the destination.
+func (ss *ShortlinkService) EncryptContent(shortlink *entities.Shortlink, password string) error {
+	contentKey := make([]byte, contentKeyLength)
+	if _, err := rand.Read(contentKey); err != nil {
+		return fmt.Errorf("failed to generate content key: %w", err)
+	}
+
+	sealedContent, err := seal(contentKey, []byte(shortlink.Content), nil)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt content: %w", err)
+	}
+
+	if err := SealRouting(shortlink, contentKey); err != nil {
+		return err
+	}
+
+	shortlink.RedirectChain = nil
+
+	salt := make([]byte, argon2idSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	passwordKey := argon2.IDKey([]byte(password), salt, ss.KDF.Iterations, ss.KDF.MemoryKiB, ss.KDF.Parallelism, contentKeyLength)
+
+	keyByPassword, err := seal(passwordKey, contentKey, nil)
+	if err != nil {
+		return fmt.Errorf("failed to wrap content key: %w", err)
+	}
+
+	keyByManagementToken, err := seal(deriveKey("management-token", shortlink.ManagementToken), contentKey, nil)
+	if err != nil {
+		return fmt.Errorf("failed to wrap content key: %w", err)
+	}
+
+	shortlink.Content = base64.RawStdEncoding.EncodeToString(sealedContent)
+	shortlink.ContentKeys = entities.StringMap{
+		contentKeyByPassword:        formatArgon2id(ss.KDF, salt, keyByPassword),
+		contentKeyByManagementToken: base64.RawStdEncoding.EncodeToString(keyByManagementToken),
+	}
+
+	return nil
+}
+
+// ContentKeyFromPassword unwraps a shortlink's content key with its password, or returns nil if
+// the content is not encrypted.
+func (ss *ShortlinkService) ContentKeyFromPassword(shortlink *entities.Shortlink, password string) ([]byte, error) {
+	if !ss.IsContentEncrypted(shortlink) {
+		return nil, nil
+	}
+
+	params, salt, keyByPassword, err := parseArgon2id(shortlink.ContentKeys[contentKeyByPassword])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse content key: %w", err)
+	}
+
+	passwordKey := argon2.IDKey([]byte(password), salt, params.Iterations, params.MemoryKiB, params.Parallelism, contentKeyLength)
+
+	contentKey, err := open(passwordKey, keyByPassword, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap content key: %w", err)
+	}
+
+	return contentKey, nil
+}
+
+// ContentKeyFromManagementToken unwraps a shortlink's content key with its management token, or returns
+// nil if the content is not encrypted.
+func (ss *ShortlinkService) ContentKeyFromManagementToken(shortlink *entities.Shortlink, token string) ([]byte, error) {
+	if !ss.IsContentEncrypted(shortlink) {
+		return nil, nil
+	}
+
+	keyByManagementToken, err := base64.RawStdEncoding.DecodeString(shortlink.ContentKeys[contentKeyByManagementToken])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse content key: %w", err)
+	}
+
+	contentKey, err := open(deriveKey("management-token", token), keyByManagementToken, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap content key: %w", err)
+	}
+
+	return contentKey, nil
+}
+
+// DecryptContent decrypts a shortlink's content in place with its content key. No-op if the content is not encrypted.
+func (ss *ShortlinkService) DecryptContent(shortlink *entities.Shortlink, contentKey []byte) error {
+	if !ss.IsContentEncrypted(shortlink) {
+		return nil
+	}
+
+	sealedContent, err := base64.RawStdEncoding.DecodeString(shortlink.Content)
+	if err != nil {
+		return fmt.Errorf("failed to parse encrypted content: %w", err)
+	}
+
+	content, err := open(contentKey, sealedContent, nil)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt content: %w", err)
+	}
+
+	shortlink.Content = string(content)
+
+	if shortlink.SealedRouting == "" {
+		return nil
+	}
+
+	sealedRouting, err := base64.RawStdEncoding.DecodeString(shortlink.SealedRouting)
+	if err != nil {
+		return fmt.Errorf("failed to parse encrypted routing: %w", err)
+	}
+
+	routingJSON, err := open(contentKey, sealedRouting, nil)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt routing: %w", err)
+	}
+
+	var routing protectedRouting
+	if err := json.Unmarshal(routingJSON, &routing); err != nil {
+		return fmt.Errorf("failed to parse decrypted routing: %w", err)
+	}
+
+	shortlink.RoutingRules = routing.RoutingRules
+	shortlink.Variants = routing.Variants
+	shortlink.SealedRouting = ""
+
+	return nil
+}
+
+// protectedRouting holds the routing rules and variants of a protected shortlink, encrypted together.
+type protectedRouting struct {
+	RoutingRules entities.RoutingRules `json:"routing_rules,omitempty"`
+	Variants     entities.Variants     `json:"variants,omitempty"`
+}
+
+// SealRouting encrypts a shortlink's routing rules and variants in place with its content key,
+// clearing them so that only the encrypted form is stored. No-op if there are none.
+func SealRouting(shortlink *entities.Shortlink, contentKey []byte) error {
+	shortlink.SealedRouting = ""
+
+	if len(shortlink.RoutingRules) == 0 && len(shortlink.Variants) == 0 {
+		return nil
+	}
+
+	routingJSON, err := json.Marshal(protectedRouting{RoutingRules: shortlink.RoutingRules, Variants: shortlink.Variants})
+	if err != nil {
+		return fmt.Errorf("failed to serialize routing: %w", err)
+	}
+
+	sealedRouting, err := seal(contentKey, routingJSON, nil)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt routing: %w", err)
+	}
+
+	shortlink.SealedRouting = base64.RawStdEncoding.EncodeToString(sealedRouting)
+	shortlink.RoutingRules = nil
+	shortlink.Variants = nil
+
+	return nil
+}
+
+// SealContentKey wraps a content key with a key derived from a secret, for a purpose such as
+// an unlock session or a share token, so that it can be handed to the client. Empty if there is no content key.
+func SealContentKey(purpose, secret string, contentKey []byte) (string, error) {
+	if contentKey == nil {
+		return "", nil
+	}
+
+	sealed, err := seal(deriveKey(purpose, secret), contentKey, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// OpenContentKey unwraps a content key wrapped by SealContentKey, or returns nil if empty.
+func OpenContentKey(purpose, secret, sealed string) ([]byte, error) {
+	if sealed == "" {
+		return nil, nil
+	}
+
+	bytes, err := base64.RawURLEncoding.DecodeString(sealed)
+	if err != nil {
+		return nil, err
+	}
+
+	return open(deriveKey(purpose, secret), bytes, nil)
+}
+
+// deriveKey derives an AES-256 key from a high-entropy secret, separated by purpose.
+func deriveKey(purpose, secret string) []byte {
+	sum := sha256.Sum256([]byte(purpose + "|" + secret))
+	return sum[:]
+}
+
+// seal encrypts with AES-GCM, prefixing the nonce, and authenticates optional associated data.
+func seal(key, plaintext, associatedData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, associatedData), nil
+}
+
+// open decrypts with AES-GCM what was encrypted by seal.
+func open(key, sealed, associatedData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("ciphertext is too short")
+	}
+
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+
+	return gcm.Open(nil, nonce, ciphertext, associatedData)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
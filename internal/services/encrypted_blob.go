@@ -0,0 +1,37 @@
+package services
+
+import (
+	"encoding/base64"
+	"strings"
+
+	"github.com/ivov/n8n-shortlink/internal/errors"
+)
+
+// An encrypted shortlink holds a workflow encrypted in the browser with AES-256-GCM, under a key kept
+// in the URL fragment and so never sent to the server. The server only stores and returns the blob,
+// i.e. a version header followed by the base64url-encoded IV and ciphertext, e.g. `v1.<iv|ciphertext>`.
+const (
+	encryptedBlobVersion = "v1."
+	encryptedBlobMaxSize = 4 * 1024 * 1024 // 4 MB
+	encryptedBlobMinSize = 12 + 16         // IV plus GCM tag, for an empty plaintext
+)
+
+// ValidateEncryptedBlob checks if the content of an encrypted shortlink is a well-formed blob within
+// the size limit. Its plaintext is opaque to the server, so it is exempt from JSON and URL policy checks.
+func (ss *ShortlinkService) ValidateEncryptedBlob(content string) error {
+	if len(content) > encryptedBlobMaxSize {
+		return errors.ErrEncryptedBlobInvalid
+	}
+
+	encoded, ok := strings.CutPrefix(content, encryptedBlobVersion)
+	if !ok {
+		return errors.ErrEncryptedBlobInvalid
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(decoded) < encryptedBlobMinSize {
+		return errors.ErrEncryptedBlobInvalid
+	}
+
+	return nil
+}
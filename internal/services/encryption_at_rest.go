@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ivov/n8n-shortlink/internal/db/entities"
+	"github.com/ivov/n8n-shortlink/internal/log"
+)
+
+const reencryptBatchSize = 100
+
+// encryptAtRest encrypts a shortlink's content in place with the active master key, bound to its slug.
+// No-op if there is no keyring.
+func (ss *ShortlinkService) encryptAtRest(shortlink *entities.Shortlink) error {
+	if ss.Keyring == nil {
+		return nil
+	}
+
+	envelope, err := ss.Keyring.Seal(shortlink.Content, shortlink.Slug)
+	if err != nil {
+		return err
+	}
+
+	shortlink.Content = envelope.Ciphertext
+	shortlink.EncryptionKeyID = envelope.KeyID
+	shortlink.EncryptedDataKey = envelope.DataKey
+
+	return nil
+}
+
+// DecryptAtRest decrypts a shortlink's content in place as read from the DB. No-op if its content is in plaintext.
+func (ss *ShortlinkService) DecryptAtRest(slug string, shortlink *entities.Shortlink) error {
+	return decryptAtRest(ss.Keyring, slug, shortlink)
+}
+
+func decryptAtRest(keyring *Keyring, slug string, shortlink *entities.Shortlink) error {
+	if shortlink.EncryptionKeyID == "" {
+		return nil
+	}
+
+	if keyring == nil {
+		return fmt.Errorf("failed to decrypt content of %q: no encryption keys configured", slug)
+	}
+
+	content, err := keyring.Open(Envelope{
+		KeyID:      shortlink.EncryptionKeyID,
+		DataKey:    shortlink.EncryptedDataKey,
+		Ciphertext: shortlink.Content,
+	}, slug)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt content of %q: %w", slug, err)
+	}
+
+	shortlink.Content = content
+
+	return nil
+}
+
+// ReencryptAll encrypts with the active master key the content of all shortlinks that are in plaintext
+// or encrypted with another master key, in batches, and returns how many were re-encrypted. Run after
+// adding a new active key, so that the old keys can be removed from the keyring.
+func (ss *ShortlinkService) ReencryptAll(ctx context.Context) (int, error) {
+	if ss.Keyring == nil {
+		return 0, fmt.Errorf("no encryption keys configured")
+	}
+
+	query := `
+		SELECT
+			slug, content,
+			COALESCE(encryption_key_id, '') AS encryption_key_id,
+			COALESCE(encrypted_data_key, '') AS encrypted_data_key
+		FROM shortlinks
+		WHERE (encryption_key_id IS NULL OR encryption_key_id != $1) AND slug > $2
+		ORDER BY slug
+		LIMIT $3;
+	`
+
+	count := 0
+	lastSlug := ""
+
+	for {
+		var shortlinks []entities.Shortlink
+		if err := ss.DB.SelectContext(ctx, &shortlinks, query, ss.Keyring.ActiveKeyID(), lastSlug, reencryptBatchSize); err != nil {
+			return count, fmt.Errorf("failed to list shortlinks to re-encrypt: %w", err)
+		}
+
+		if len(shortlinks) == 0 {
+			break
+		}
+
+		tx, err := ss.DB.BeginTxx(ctx, nil)
+		if err != nil {
+			return count, err
+		}
+
+		for _, shortlink := range shortlinks {
+			if err := ss.DecryptAtRest(shortlink.Slug, &shortlink); err != nil {
+				_ = tx.Rollback()
+				return count, err
+			}
+
+			if err := ss.encryptAtRest(&shortlink); err != nil {
+				_ = tx.Rollback()
+				return count, err
+			}
+
+			_, err := tx.NamedExecContext(ctx, `
+				UPDATE shortlinks
+				SET content = :content, encryption_key_id = :encryption_key_id, encrypted_data_key = :encrypted_data_key
+				WHERE slug = :slug;
+			`, shortlink)
+			if err != nil {
+				_ = tx.Rollback()
+				return count, fmt.Errorf("failed to re-encrypt shortlink: %w", err)
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			return count, err
+		}
+
+		count += len(shortlinks)
+		lastSlug = shortlinks[len(shortlinks)-1].Slug
+
+		ss.Logger.Info("re-encrypted shortlinks", log.Int("count", count), log.Str("key_id", ss.Keyring.ActiveKeyID()))
+	}
+
+	return count, nil
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ivov/n8n-shortlink/internal/db/entities"
+)
+
+// StreamVisits calls fn with each visit to a shortlink on or after a start day and before an end day,
+// either unbounded if empty, oldest first. Rows are read one at a time, so that an export of any size
+// is never held in memory. Visits rolled up past retention are not included.
+func (vs *VisitService) StreamVisits(ctx context.Context, slug, start, end string, fn func(*entities.Visit) error) error {
+	query := `
+		SELECT
+			id, slug, ts,
+			COALESCE(referer, '') AS referer,
+			COALESCE(user_agent, '') AS user_agent,
+			COALESCE(variant, '') AS variant,
+			access_path, browser, os, device, is_bot, visitor_hash
+		FROM visits
+		WHERE slug = $1 AND ($2 = '' OR ts >= $2) AND ($3 = '' OR ts < $3)
+		ORDER BY ts, id;
+	`
+
+	rows, err := vs.DB.QueryxContext(ctx, query, slug, start, end)
+	if err != nil {
+		return fmt.Errorf("failed to export visits: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var visit entities.Visit
+		if err := rows.StructScan(&visit); err != nil {
+			return fmt.Errorf("failed to export visit: %w", err)
+		}
+
+		if err := fn(&visit); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// StreamShortlinks calls fn with the metadata of each shortlink, oldest first, excluding their content
+// and secrets. Rows are read one at a time, so that an export of any size is never held in memory.
+func (ss *ShortlinkService) StreamShortlinks(ctx context.Context, fn func(*entities.ShortlinkExport) error) error {
+	query := `
+		SELECT
+			s.slug, s.kind, s.created_at, s.expires_at, s.activate_at,
+			COALESCE(s.creator_ip, '') AS creator_ip,
+			s.redirect_type,
+			COALESCE(s.password, '') != '' AS is_protected,
+			s.encryption_key_id IS NOT NULL AS is_encrypted_at_rest,
+			(SELECT COUNT(*) FROM visits v WHERE v.slug = s.slug)
+				+ (SELECT COALESCE(SUM(r.count), 0) FROM visit_daily_rollups r WHERE r.slug = s.slug) AS visits
+		FROM shortlinks s
+		ORDER BY s.created_at, s.slug;
+	`
+
+	rows, err := ss.DB.QueryxContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to export shortlinks: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var shortlink entities.ShortlinkExport
+		if err := rows.StructScan(&shortlink); err != nil {
+			return fmt.Errorf("failed to export shortlink: %w", err)
+		}
+
+		if err := fn(&shortlink); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
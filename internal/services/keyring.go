@@ -0,0 +1,149 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var keyIDRegex = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// Keyring holds the server master keys that shortlink content is encrypted at rest with,
+// using envelope encryption: content is encrypted with a random data key per shortlink, and
+// the data key is encrypted with a master key, identified by its ID. The first key is active,
+// i.e. used to encrypt, while the rest are kept to decrypt content encrypted before a rotation.
+type Keyring struct {
+	activeKeyID string
+	keys        map[string][]byte
+}
+
+// Envelope is content encrypted at rest, along with the encrypted data key and the ID of the master key it was encrypted with.
+type Envelope struct {
+	KeyID      string
+	DataKey    string
+	Ciphertext string
+}
+
+// LoadKeyring creates a keyring from a comma-separated list of keys and from a file
+// with one key per line, where each key is `<id>:<base64-encoded 32 bytes>`. Keys in the
+// list come before keys in the file. Returns nil if no keys are supplied.
+func LoadKeyring(keys string, keyFilePath string) (*Keyring, error) {
+	var entries []string
+
+	for _, entry := range strings.Split(keys, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+
+	if keyFilePath != "" {
+		file, err := os.ReadFile(keyFilePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read encryption key file: %w", err)
+		}
+
+		scanner := bufio.NewScanner(bytes.NewReader(file))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			entries = append(entries, line)
+		}
+	}
+
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	keyring := &Keyring{keys: make(map[string][]byte)}
+
+	for _, entry := range entries {
+		id, encodedKey, ok := strings.Cut(entry, ":")
+		if !ok || !keyIDRegex.MatchString(id) {
+			return nil, fmt.Errorf("malformed encryption key, expected `<id>:<base64 key>`")
+		}
+
+		if _, exists := keyring.keys[id]; exists {
+			return nil, fmt.Errorf("duplicate encryption key ID %q", id)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encodedKey)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("encryption key %q must be 32 bytes, base64-encoded", id)
+		}
+
+		keyring.keys[id] = key
+
+		if keyring.activeKeyID == "" {
+			keyring.activeKeyID = id
+		}
+	}
+
+	return keyring, nil
+}
+
+// ActiveKeyID returns the ID of the master key that content is encrypted with.
+func (k *Keyring) ActiveKeyID() string {
+	return k.activeKeyID
+}
+
+// Seal encrypts content with a new data key under the active master key. The associated data,
+// e.g. the slug, must match on Open, so that content cannot be swapped between shortlinks.
+func (k *Keyring) Seal(plaintext, associatedData string) (Envelope, error) {
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return Envelope{}, fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	ciphertext, err := seal(dataKey, []byte(plaintext), []byte(associatedData))
+	if err != nil {
+		return Envelope{}, fmt.Errorf("failed to encrypt content: %w", err)
+	}
+
+	encryptedDataKey, err := seal(k.keys[k.activeKeyID], dataKey, []byte(k.activeKeyID))
+	if err != nil {
+		return Envelope{}, fmt.Errorf("failed to encrypt data key: %w", err)
+	}
+
+	return Envelope{
+		KeyID:      k.activeKeyID,
+		DataKey:    base64.StdEncoding.EncodeToString(encryptedDataKey),
+		Ciphertext: base64.StdEncoding.EncodeToString(ciphertext),
+	}, nil
+}
+
+// Open decrypts content encrypted by Seal under any master key in the keyring.
+func (k *Keyring) Open(envelope Envelope, associatedData string) (string, error) {
+	masterKey, ok := k.keys[envelope.KeyID]
+	if !ok {
+		return "", fmt.Errorf("unknown encryption key ID %q", envelope.KeyID)
+	}
+
+	encryptedDataKey, err := base64.StdEncoding.DecodeString(envelope.DataKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse data key: %w", err)
+	}
+
+	dataKey, err := open(masterKey, encryptedDataKey, []byte(envelope.KeyID))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt data key: %w", err)
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(envelope.Ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse encrypted content: %w", err)
+	}
+
+	plaintext, err := open(dataKey, ciphertext, []byte(associatedData))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt content: %w", err)
+	}
+
+	return string(plaintext), nil
+}
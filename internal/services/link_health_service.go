@@ -0,0 +1,247 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ivov/n8n-shortlink/internal/db/entities"
+	"github.com/ivov/n8n-shortlink/internal/log"
+	"github.com/jmoiron/sqlx"
+)
+
+// LinkHealthService checks whether the destinations of URL shortlinks are reachable.
+type LinkHealthService struct {
+	DB     *sqlx.DB
+	Logger *log.Logger
+	// Client sends the check requests. Replaceable for testing.
+	Client *http.Client
+	// Timeout is the max duration of a single destination check.
+	Timeout time.Duration
+	// Concurrency is the max number of destinations checked at the same time.
+	Concurrency int
+	// Keyring holds the master keys to decrypt destinations encrypted at rest with.
+	Keyring *Keyring
+}
+
+const healthCheckUserAgent = "n8n-shortlink-health-check/1.0"
+
+// Start checks all destinations once on start and then once per interval until the context is cancelled.
+func (hs *LinkHealthService) Start(ctx context.Context, interval time.Duration) {
+	if err := hs.CheckAll(ctx); err != nil {
+		hs.Logger.Error(err)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := hs.CheckAll(ctx); err != nil {
+				hs.Logger.Error(err)
+			}
+		}
+	}
+}
+
+// CheckAll checks the destinations of all unprotected URL shortlinks, including those of their
+// routing rules and variants, and records the results. Password-protected shortlinks are skipped
+// so as not to probe their destinations.
+func (hs *LinkHealthService) CheckAll(ctx context.Context) error {
+	var shortlinks []entities.Shortlink
+	query := `
+		SELECT
+			slug, content, routing_rules, variants,
+			COALESCE(encryption_key_id, '') AS encryption_key_id,
+			COALESCE(encrypted_data_key, '') AS encrypted_data_key
+		FROM shortlinks
+		WHERE kind = 'url' AND (password IS NULL OR password = '');
+	`
+
+	if err := hs.DB.SelectContext(ctx, &shortlinks, query); err != nil {
+		return fmt.Errorf("failed to list URL shortlinks: %w", err)
+	}
+
+	for i := range shortlinks {
+		if err := decryptAtRest(hs.Keyring, shortlinks[i].Slug, &shortlinks[i]); err != nil {
+			return err
+		}
+	}
+
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, max(hs.Concurrency, 1))
+
+	for _, shortlink := range shortlinks {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return ctx.Err()
+		case semaphore <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			health := hs.checkDestinations(ctx, destinations(shortlink))
+			health.Slug = shortlink.Slug
+
+			if err := hs.saveHealth(ctx, health); err != nil {
+				hs.Logger.Error(err, log.Str("slug", shortlink.Slug))
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	hs.Logger.Info("checked shortlink destinations", log.Int("count", len(shortlinks)))
+
+	return nil
+}
+
+// Check sends a HEAD request to a destination, falling back to GET if HEAD is unsupported.
+// A destination is broken if it cannot be reached or responds with a 4xx or 5xx status.
+func (hs *LinkHealthService) Check(ctx context.Context, destination string) entities.LinkHealth {
+	ctx, cancel := context.WithTimeout(ctx, hs.Timeout)
+	defer cancel()
+
+	statusCode, err := hs.request(ctx, http.MethodHead, destination)
+	if err != nil || statusCode == http.StatusMethodNotAllowed || statusCode == http.StatusNotImplemented {
+		statusCode, err = hs.request(ctx, http.MethodGet, destination)
+	}
+
+	if err != nil {
+		errMsg := err.Error()
+		return entities.LinkHealth{IsBroken: true, Error: &errMsg}
+	}
+
+	return entities.LinkHealth{
+		StatusCode: &statusCode,
+		IsBroken:   statusCode >= http.StatusBadRequest,
+	}
+}
+
+// checkDestinations checks the destinations of a shortlink in turn, returning the result of the
+// first broken one, or else of the first destination, i.e. the shortlink's content.
+func (hs *LinkHealthService) checkDestinations(ctx context.Context, destinations []string) entities.LinkHealth {
+	var health entities.LinkHealth
+
+	for i, destination := range destinations {
+		result := hs.Check(ctx, destination)
+
+		if result.IsBroken {
+			if i > 0 { // name the destination since it is not the content
+				errMsg := "broken destination " + destination
+				if result.Error != nil {
+					errMsg += ": " + *result.Error
+				}
+				result.Error = &errMsg
+			}
+			return result
+		}
+
+		if i == 0 {
+			health = result
+		}
+	}
+
+	return health
+}
+
+// destinations lists the distinct destinations of a URL shortlink, starting with its content.
+func destinations(shortlink entities.Shortlink) []string {
+	list := []string{shortlink.Content}
+
+	for _, rule := range shortlink.RoutingRules {
+		if !slices.Contains(list, rule.Destination) {
+			list = append(list, rule.Destination)
+		}
+	}
+
+	for _, variant := range shortlink.Variants {
+		if !slices.Contains(list, variant.Destination) {
+			list = append(list, variant.Destination)
+		}
+	}
+
+	return list
+}
+
+func (hs *LinkHealthService) request(ctx context.Context, method, destination string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, destination, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("User-Agent", healthCheckUserAgent)
+
+	resp, err := hs.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024)) // allow connection reuse
+
+	return resp.StatusCode, nil
+}
+
+func (hs *LinkHealthService) saveHealth(ctx context.Context, health entities.LinkHealth) error {
+	query := `
+		INSERT INTO link_health (slug, status_code, is_broken, error, checked_at)
+		VALUES (:slug, :status_code, :is_broken, :error, CURRENT_TIMESTAMP)
+		ON CONFLICT (slug) DO UPDATE SET
+			status_code = excluded.status_code,
+			is_broken = excluded.is_broken,
+			error = excluded.error,
+			checked_at = excluded.checked_at;
+	`
+
+	if _, err := hs.DB.NamedExecContext(ctx, query, health); err != nil {
+		return fmt.Errorf("failed to save link health: %w", err)
+	}
+
+	if health.IsBroken {
+		hs.Logger.Info("found broken shortlink destination", log.Str("slug", health.Slug))
+	}
+
+	return nil
+}
+
+// GetBySlug retrieves the latest check result for a shortlink, or nil if never checked.
+func (hs *LinkHealthService) GetBySlug(slug string) (*entities.LinkHealth, error) {
+	var health entities.LinkHealth
+	query := "SELECT slug, status_code, is_broken, error, checked_at FROM link_health WHERE slug = $1;"
+
+	err := hs.DB.Get(&health, query, slug)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return &health, nil
+}
+
+// CountBroken counts shortlinks whose destination was broken on the latest check.
+func (hs *LinkHealthService) CountBroken() (int, error) {
+	var count int
+
+	err := hs.DB.Get(&count, "SELECT COUNT(*) FROM link_health WHERE is_broken = 1;")
+	if err != nil {
+		return 0, fmt.Errorf("failed to count broken shortlinks: %w", err)
+	}
+
+	return count, nil
+}
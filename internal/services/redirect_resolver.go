@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/ivov/n8n-shortlink/internal/errors"
+)
+
+const resolverUserAgent = "n8n-shortlink-resolver/1.0"
+
+// RedirectResolver follows the redirects of a URL, e.g. through other shorteners, to find its final destination.
+type RedirectResolver struct {
+	// Client sends the requests for each hop. Replaceable for testing.
+	Client *http.Client
+	// MaxHops is the max number of redirects to follow.
+	MaxHops int
+	// Timeout is the max duration of the entire resolution.
+	Timeout time.Duration
+}
+
+// Resolve returns the chain of URLs visited, starting with the given URL and ending with
+// the final destination. If a hop cannot be reached, the chain up to that hop is returned
+// along with the error. If the chain exceeds the max number of hops, ErrRedirectChainTooLong
+// is returned.
+func (rr *RedirectResolver) Resolve(ctx context.Context, rawURL string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, rr.Timeout)
+	defer cancel()
+
+	client := *rr.Client
+	client.CheckRedirect = func(_ *http.Request, _ []*http.Request) error {
+		return http.ErrUseLastResponse // follow redirects one hop at a time
+	}
+
+	chain := []string{rawURL}
+	current := rawURL
+
+	for hops := 0; ; hops++ {
+		location, err := rr.nextHop(ctx, &client, current)
+		if err != nil {
+			return chain, err
+		}
+
+		if location == "" {
+			return chain, nil
+		}
+
+		if hops == rr.MaxHops {
+			return chain, errors.ErrRedirectChainTooLong
+		}
+
+		chain = append(chain, location)
+		current = location
+	}
+}
+
+// nextHop returns the absolute URL that a URL redirects to, or an empty string if it does not redirect.
+func (rr *RedirectResolver) nextHop(ctx context.Context, client *http.Client, rawURL string) (string, error) {
+	resp, err := rr.request(ctx, client, http.MethodHead, rawURL)
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+		resp, err = rr.request(ctx, client, http.MethodGet, rawURL)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", rawURL, err)
+	}
+
+	location := resp.Header.Get("Location")
+	if resp.StatusCode < 300 || resp.StatusCode > 399 || location == "" {
+		return "", nil
+	}
+
+	base, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	next, err := base.Parse(location)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse redirect location %q: %w", location, err)
+	}
+
+	return next.String(), nil
+}
+
+func (rr *RedirectResolver) request(ctx context.Context, client *http.Client, method, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("User-Agent", resolverUserAgent)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024)) // allow connection reuse
+	resp.Body.Close()
+
+	return resp, nil
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ivov/n8n-shortlink/internal/db/entities"
+	"github.com/ivov/n8n-shortlink/internal/errors"
+	"github.com/ivov/n8n-shortlink/internal/log"
+	"github.com/ivov/n8n-shortlink/internal/useragent"
+)
+
+const maxRoutingRules = 20
+
+// ValidateRoutingRules checks if routing rules are well-formed and their destinations, along with
+// every URL they redirect through, pass the URL policy.
+func (ss *ShortlinkService) ValidateRoutingRules(ctx context.Context, rules entities.RoutingRules) error {
+	if len(rules) > maxRoutingRules {
+		return fmt.Errorf("%w: max %d rules", errors.ErrRoutingRulesInvalid, maxRoutingRules)
+	}
+
+	destinations := make([]string, 0, len(rules))
+
+	for i, rule := range rules {
+		if len(rule.Languages) == 0 && rule.Device == "" && rule.RefererHost == "" {
+			return fmt.Errorf("%w: rule %d has no conditions", errors.ErrRoutingRulesInvalid, i)
+		}
+
+		for _, language := range rule.Languages {
+			if language == "" {
+				return fmt.Errorf("%w: rule %d has an empty language", errors.ErrRoutingRulesInvalid, i)
+			}
+		}
+
+		if rule.Device != "" && rule.Device != useragent.DeviceMobile && rule.Device != useragent.DeviceDesktop {
+			return fmt.Errorf("%w: rule %d has unsupported device %q", errors.ErrRoutingRulesInvalid, i, rule.Device)
+		}
+
+		if _, err := url.ParseRequestURI(rule.Destination); err != nil {
+			return fmt.Errorf("%w: rule %d has malformed destination", errors.ErrRoutingRulesInvalid, i)
+		}
+
+		if err := ss.ValidateContent(rule.Destination); err != nil {
+			return fmt.Errorf("rule %d: %w", i, err)
+		}
+
+		destinations = append(destinations, rule.Destination)
+	}
+
+	return ss.validateRedirects(ctx, destinations)
+}
+
+// UpdateRoutingRules replaces the routing rules of a shortlink. If protected, the shortlink must be
+// decrypted with its content key, which encrypts the new rules along with the variants.
+func (ss *ShortlinkService) UpdateRoutingRules(shortlink *entities.Shortlink, rules entities.RoutingRules, contentKey []byte) error {
+	shortlink.RoutingRules = rules
+
+	if contentKey != nil {
+		if err := SealRouting(shortlink, contentKey); err != nil {
+			return err
+		}
+	}
+
+	query := "UPDATE shortlinks SET routing_rules = :routing_rules, sealed_routing = NULLIF(:sealed_routing, '') WHERE slug = :slug;"
+
+	if _, err := ss.DB.NamedExec(query, shortlink); err != nil {
+		return fmt.Errorf("failed to update routing rules: %w", err)
+	}
+
+	ss.Logger.Info("user updated routing rules", log.Str("slug", shortlink.Slug), log.Int("count", len(rules)))
+
+	return nil
+}
+
+// RouteDestination returns the destination of the first routing rule matched by a visitor,
+// and whether any matched, falling back to the shortlink's content if none match.
+func (ss *ShortlinkService) RouteDestination(shortlink *entities.Shortlink, acceptLanguage, userAgent, referer string) (string, bool) {
+	if len(shortlink.RoutingRules) == 0 {
+		return shortlink.Content, false
+	}
+
+	language := preferredLanguage(acceptLanguage)
+	device := useragent.DeviceClass(userAgent)
+	refererHost := ""
+	if u, err := url.Parse(referer); err == nil {
+		refererHost = strings.ToLower(u.Hostname())
+	}
+
+	for _, rule := range shortlink.RoutingRules {
+		if len(rule.Languages) > 0 && !matchesLanguage(language, rule.Languages) {
+			continue
+		}
+
+		if rule.Device != "" && rule.Device != device {
+			continue
+		}
+
+		if rule.RefererHost != "" {
+			ruleHost := strings.ToLower(rule.RefererHost)
+			if refererHost != ruleHost && !strings.HasSuffix(refererHost, "."+ruleHost) {
+				continue
+			}
+		}
+
+		return rule.Destination, true
+	}
+
+	return shortlink.Content, false
+}
+
+// preferredLanguage returns the lowercased language tag with the highest quality
+// in an Accept-Language header, or an empty string if there is none.
+func preferredLanguage(acceptLanguage string) string {
+	type weightedTag struct {
+		tag     string
+		quality float64
+	}
+
+	var tags []weightedTag
+
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			quality = parsed
+		}
+
+		if quality > 0 {
+			tags = append(tags, weightedTag{strings.ToLower(tag), quality})
+		}
+	}
+
+	if len(tags) == 0 {
+		return ""
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].quality > tags[j].quality })
+
+	return tags[0].tag
+}
+
+// matchesLanguage checks if a language tag is one of the rule languages or a subtag of one,
+// e.g. "en-us" matches "en".
+func matchesLanguage(language string, ruleLanguages []string) bool {
+	if language == "" {
+		return false
+	}
+
+	for _, ruleLanguage := range ruleLanguages {
+		ruleLanguage = strings.ToLower(ruleLanguage)
+		if language == ruleLanguage || strings.HasPrefix(language, ruleLanguage+"-") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ivov/n8n-shortlink/internal/db/entities"
+	"github.com/ivov/n8n-shortlink/internal/log"
+)
+
+// RotateShareSecret replaces the secret that a shortlink's share tokens are signed with,
+// revoking all share tokens minted so far.
+func (ss *ShortlinkService) RotateShareSecret(slug string, shortlink *entities.Shortlink) error {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return err
+	}
+
+	secret := base64.RawURLEncoding.EncodeToString(bytes)
+
+	if _, err := ss.DB.Exec("UPDATE shortlinks SET share_secret = $1 WHERE slug = $2;", secret, slug); err != nil {
+		return fmt.Errorf("failed to rotate share secret: %w", err)
+	}
+
+	shortlink.ShareSecret = secret
+
+	ss.Logger.Info("rotated share secret", log.Str("slug", slug))
+
+	return nil
+}
+
+const shareTokenKeyPurpose = "share-token"
+
+// MintShareToken creates a token granting access to a protected shortlink until it expires
+// or the shortlink's share secret is rotated. The token carries the content key of an encrypted
+// shortlink, sealed with the share secret.
+func (ss *ShortlinkService) MintShareToken(slug string, shortlink *entities.Shortlink, ttl time.Duration, contentKey []byte) (string, time.Time, error) {
+	if shortlink.ShareSecret == "" {
+		if err := ss.RotateShareSecret(slug, shortlink); err != nil {
+			return "", time.Time{}, err
+		}
+	}
+
+	sealedKey, err := SealContentKey(shareTokenKeyPurpose, shortlink.ShareSecret, contentKey)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to seal content key: %w", err)
+	}
+
+	expiresAt := time.Now().Add(ttl).Truncate(time.Second)
+	expiry := expiresAt.Unix()
+
+	token := strconv.FormatInt(expiry, 10) + "." + signShareToken(slug, shortlink.ShareSecret, expiry, sealedKey)
+	if sealedKey != "" {
+		token += "." + sealedKey
+	}
+
+	return token, expiresAt, nil
+}
+
+// VerifyShareToken checks if a share token is unexpired and signed with a shortlink's current share secret,
+// and returns the content key it carries, which is nil for unencrypted shortlinks.
+func (ss *ShortlinkService) VerifyShareToken(slug string, shortlink *entities.Shortlink, token string) ([]byte, bool) {
+	if shortlink.ShareSecret == "" {
+		return nil, false
+	}
+
+	parts := strings.SplitN(token, ".", 3) // expiry, signature, optional sealed content key
+	if len(parts) < 2 {
+		return nil, false
+	}
+
+	expiry, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || time.Now().Unix() >= expiry {
+		return nil, false
+	}
+
+	sealedKey := ""
+	if len(parts) == 3 {
+		sealedKey = parts[2]
+	}
+
+	if !hmac.Equal([]byte(parts[1]), []byte(signShareToken(slug, shortlink.ShareSecret, expiry, sealedKey))) {
+		return nil, false
+	}
+
+	contentKey, err := OpenContentKey(shareTokenKeyPurpose, shortlink.ShareSecret, sealedKey)
+	if err != nil {
+		return nil, false
+	}
+
+	return contentKey, true
+}
+
+func signShareToken(slug, secret string, expiry int64, sealedKey string) string {
+	message := slug + "|" + strconv.FormatInt(expiry, 10)
+	if sealedKey != "" {
+		message += "|" + sealedKey
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(message))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -1,32 +1,77 @@
 package services
 
 import (
+	"context"
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
 	"database/sql"
 	"encoding/base64"
+	"encoding/hex"
+	stdErrors "errors"
 	"fmt"
+	"net/http"
+	"net/url"
 	"regexp"
-	"strings"
+	"slices"
+	"sync"
+	"time"
 
 	"github.com/ivov/n8n-shortlink/internal/db/entities"
 	"github.com/ivov/n8n-shortlink/internal/errors"
 	"github.com/ivov/n8n-shortlink/internal/log"
+	"github.com/ivov/n8n-shortlink/internal/policy"
 	"github.com/jmoiron/sqlx"
-	"golang.org/x/crypto/bcrypt"
 )
 
 // ShortlinkService manages shortlinks.
 type ShortlinkService struct {
 	DB     *sqlx.DB
 	Logger *log.Logger
+	Policy *policy.Engine
+	// Hasher hashes and verifies shortlink passwords.
+	Hasher PasswordHasher
+	// KDF are the argon2id params to derive keys from passwords to encrypt content with.
+	KDF Argon2idParams
+	// Keyring holds the master keys to encrypt content at rest with. Content is stored in plaintext if nil.
+	Keyring *Keyring
+	// Resolver follows redirects of URLs to police their final destinations. Optional.
+	Resolver *RedirectResolver
+	// IPHasher hashes creator IPs before storing them. Creator IPs are stored in plaintext if nil.
+	IPHasher *VisitorHasher
 }
 
-// SaveShortlink writes a shortlink to the DB.
+// SaveShortlink writes a shortlink to the DB, encrypting its content at rest if there is a keyring
+// and hashing its creator IP if there is an IP hasher.
 func (ss *ShortlinkService) SaveShortlink(shortlink *entities.Shortlink) (*entities.Shortlink, error) {
+	content := shortlink.Content
+
+	if ss.IPHasher != nil && shortlink.CreatorIP != "" {
+		creatorIPHash, err := ss.IPHasher.Hash(shortlink.CreatorIP)
+		if err != nil {
+			return nil, err
+		}
+		shortlink.CreatorIP = creatorIPHash
+	}
+
+	if err := ss.encryptAtRest(shortlink); err != nil {
+		return nil, err
+	}
+
 	query := `
-		INSERT INTO shortlinks (slug, kind, content, creator_ip, expires_at, password, allowed_visits)
-		VALUES (:slug, :kind, :content, :creator_ip, :expires_at, :password, :allowed_visits)
-		RETURNING slug, kind, content, creator_ip, created_at, expires_at, password, allowed_visits;
+		INSERT INTO shortlinks (
+			slug, kind, content, creator_ip, expires_at, activate_at, password, allowed_visits, redirect_type,
+			force_preview, forward_query, utm_params, redirect_chain, routing_rules, variants, sticky_variants,
+			management_token_hash, content_keys, sealed_routing, encryption_key_id, encrypted_data_key
+		)
+		VALUES (
+			:slug, :kind, :content, :creator_ip, :expires_at, :activate_at, :password, :allowed_visits, :redirect_type,
+			:force_preview, :forward_query, :utm_params, :redirect_chain, :routing_rules, :variants, :sticky_variants,
+			:management_token_hash, :content_keys, NULLIF(:sealed_routing, ''), NULLIF(:encryption_key_id, ''), NULLIF(:encrypted_data_key, '')
+		)
+		RETURNING
+			slug, kind, content, creator_ip, created_at, expires_at, activate_at, password, allowed_visits, redirect_type,
+			force_preview, forward_query, utm_params, routing_rules, variants, sticky_variants;
 	`
 
 	rows, err := ss.DB.NamedQuery(query, shortlink)
@@ -44,6 +89,8 @@ func (ss *ShortlinkService) SaveShortlink(shortlink *entities.Shortlink) (*entit
 		return nil, errors.ErrShortlinkNotFound
 	}
 
+	shortlink.Content = content
+
 	ss.Logger.Info(
 		"user created shortlink",
 		log.Str("slug", shortlink.Slug),
@@ -95,10 +142,31 @@ func (ss *ShortlinkService) isSlugUnique(slug string) (bool, error) {
 	return !exists, nil
 }
 
+// IsActive checks if a shortlink has no activation time or its activation time has arrived.
+func (ss *ShortlinkService) IsActive(shortlink *entities.Shortlink) bool {
+	return shortlink.ActivateAt == nil || !time.Now().Before(shortlink.ActivateAt.Time)
+}
+
+// IsExpired checks if a shortlink has an expiration time and it has passed.
+func (ss *ShortlinkService) IsExpired(shortlink *entities.Shortlink) bool {
+	return shortlink.ExpiresAt != nil && !time.Now().Before(shortlink.ExpiresAt.Time)
+}
+
 // GetBySlug retrieves the main parts of a shortlink by its slug.
 func (ss *ShortlinkService) GetBySlug(slug string) (*entities.Shortlink, error) {
 	var shortlink entities.Shortlink
-	query := "SELECT kind, content, password FROM shortlinks WHERE slug = $1;"
+	query := `
+		SELECT
+			kind, content, created_at, expires_at, activate_at, password, redirect_type, force_preview, forward_query, utm_params,
+			routing_rules, variants, sticky_variants,
+			COALESCE(management_token_hash, '') AS management_token_hash, -- NULL for shortlinks predating tokens
+			COALESCE(share_secret, '') AS share_secret, content_keys,
+			COALESCE(sealed_routing, '') AS sealed_routing, -- NULL unless protected with rules or variants
+			COALESCE(encryption_key_id, '') AS encryption_key_id, -- NULL for plaintext content
+			COALESCE(encrypted_data_key, '') AS encrypted_data_key
+		FROM shortlinks
+		WHERE slug = $1;
+	`
 
 	err := ss.DB.Get(&shortlink, query, slug)
 	if err != nil {
@@ -109,9 +177,61 @@ func (ss *ShortlinkService) GetBySlug(slug string) (*entities.Shortlink, error)
 		return nil, err
 	}
 
+	if err := ss.DecryptAtRest(slug, &shortlink); err != nil {
+		return nil, err
+	}
+
 	return &shortlink, nil
 }
 
+// CountByKind counts stored shortlinks by kind.
+func (ss *ShortlinkService) CountByKind() (map[string]int, error) {
+	var rows []struct {
+		Kind  string `db:"kind"`
+		Count int    `db:"count"`
+	}
+
+	err := ss.DB.Select(&rows, "SELECT kind, COUNT(*) AS count FROM shortlinks GROUP BY kind;")
+	if err != nil {
+		return nil, fmt.Errorf("failed to count shortlinks by kind: %w", err)
+	}
+
+	counts := make(map[string]int, len(rows))
+	for _, row := range rows {
+		counts[row.Kind] = row.Count
+	}
+
+	return counts, nil
+}
+
+// GenerateManagementToken generates a random token for managing a shortlink, along with
+// the hash to store. Being high-entropy, the token is hashed with SHA-256 rather than bcrypt.
+func (ss *ShortlinkService) GenerateManagementToken() (token string, hash string, err error) {
+	bytes := make([]byte, 32)
+
+	if _, err := rand.Read(bytes); err != nil {
+		return "", "", err
+	}
+
+	token = base64.RawURLEncoding.EncodeToString(bytes)
+
+	return token, hashManagementToken(token), nil
+}
+
+// VerifyManagementToken compares a stored management token hash with a plaintext token.
+func (ss *ShortlinkService) VerifyManagementToken(hash, token string) bool {
+	if hash == "" || token == "" {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(hash), []byte(hashManagementToken(token))) == 1
+}
+
+func hashManagementToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 // ValidateUserSlug checks if a user-provided slug meets all requirements.
 func (ss *ShortlinkService) ValidateUserSlug(slug string) error {
 	if len(slug) < defaultSlugLength {
@@ -142,7 +262,7 @@ func (ss *ShortlinkService) ValidateUserSlug(slug string) error {
 	return nil
 }
 
-var reservedSlugs = []string{"static", "health", "metrics", "docs", "spec", "challenge"}
+var reservedSlugs = []string{"static", "health", "metrics", "docs", "spec", "challenge", "admin"}
 
 func isReserved(path string) bool {
 	for _, deny := range reservedSlugs {
@@ -154,9 +274,9 @@ func isReserved(path string) bool {
 	return false
 }
 
-// HashPassword generates a bcrypt hash of a plaintext password.
+// HashPassword generates a hash of a plaintext password.
 func (ss *ShortlinkService) HashPassword(plaintextPassword string) (string, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(plaintextPassword), bcrypt.DefaultCost)
+	hash, err := ss.Hasher.Hash(plaintextPassword)
 	if err != nil {
 		ss.Logger.Error(err)
 		return "", fmt.Errorf("failed to hash password: %w", err)
@@ -168,13 +288,81 @@ func (ss *ShortlinkService) HashPassword(plaintextPassword string) (string, erro
 // ValidateKind checks if a kind is supported.
 func (ss *ShortlinkService) ValidateKind(kind string) error {
 	switch kind {
-	case "workflow", "url":
+	case "workflow", "url", "encrypted":
 		return nil
 	default:
 		return fmt.Errorf("found unsupported kind: %s", kind)
 	}
 }
 
+// ValidateRedirectType checks if a redirect type is a supported redirect status code.
+func (ss *ShortlinkService) ValidateRedirectType(redirectType int) error {
+	switch redirectType {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return nil
+	default:
+		return errors.ErrRedirectTypeUnsupported
+	}
+}
+
+var utmKeys = []string{"utm_source", "utm_medium", "utm_campaign", "utm_term", "utm_content"}
+
+// ValidateUTMParams checks if UTM params only contain known keys with non-empty values.
+func (ss *ShortlinkService) ValidateUTMParams(params map[string]string) error {
+	for key, value := range params {
+		if !slices.Contains(utmKeys, key) || value == "" {
+			return errors.ErrUTMParamsInvalid
+		}
+	}
+
+	return nil
+}
+
+// BuildRedirectURL builds the URL to send a visitor to, adding the visitor's query params
+// (if forwarding is enabled) and the shortlink's UTM params to the destination's own params.
+// Params already present take precedence, so destination params are never overridden and
+// a visitor's UTM params override the shortlink's.
+func (ss *ShortlinkService) BuildRedirectURL(shortlink *entities.Shortlink, destination string, incoming url.Values) (string, error) {
+	if !shortlink.ForwardQuery && len(shortlink.UTMParams) == 0 {
+		return destination, nil
+	}
+
+	u, err := url.Parse(destination)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse destination: %w", err)
+	}
+
+	present := u.Query()
+	additions := url.Values{}
+
+	if shortlink.ForwardQuery {
+		for key, values := range incoming {
+			if !present.Has(key) {
+				additions[key] = values
+			}
+		}
+	}
+
+	for _, key := range utmKeys {
+		value, ok := shortlink.UTMParams[key]
+		if ok && !present.Has(key) && !additions.Has(key) {
+			additions.Set(key, value)
+		}
+	}
+
+	if len(additions) == 0 {
+		return destination, nil
+	}
+
+	if u.RawQuery == "" {
+		u.RawQuery = additions.Encode()
+	} else {
+		u.RawQuery += "&" + additions.Encode()
+	}
+
+	return u.String(), nil
+}
+
 const passwordMinLength = 8
 
 // ValidatePasswordLength checks if a password's length is valid.
@@ -186,53 +374,132 @@ func (ss *ShortlinkService) ValidatePasswordLength(password string) error {
 	return nil
 }
 
-// VerifyPassword compares a bcrypt hash with a plaintext password.
-func (ss *ShortlinkService) VerifyPassword(hashedPassword, plaintextPassword string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(plaintextPassword))
-	return err == nil
+// VerifyPassword compares a shortlink's password hash with a plaintext password. If they match
+// and the hash is on an outdated algorithm or parameters, the hash is upgraded in place.
+func (ss *ShortlinkService) VerifyPassword(slug string, shortlink *entities.Shortlink, plaintextPassword string) bool {
+	ok, needsRehash := ss.Hasher.Verify(shortlink.Password, plaintextPassword)
+	if !ok || !needsRehash {
+		return ok
+	}
+
+	hash, err := ss.HashPassword(plaintextPassword)
+	if err != nil {
+		return true // keep the outdated hash
+	}
+
+	if _, err := ss.DB.Exec("UPDATE shortlinks SET password = $1 WHERE slug = $2;", hash, slug); err != nil {
+		ss.Logger.Error(fmt.Errorf("failed to rehash password: %w", err), log.Str("slug", slug))
+		return true
+	}
+
+	shortlink.Password = hash
+
+	ss.Logger.Info("rehashed password", log.Str("slug", slug))
+
+	return true
+}
+
+// ValidateContent checks a URL against the URL policy, returning a *policy.Violation if blocked.
+func (ss *ShortlinkService) ValidateContent(content string) error {
+	return ss.Policy.Evaluate(content)
 }
 
-var suspiciousPatterns = []string{
-	"cpanel.site",
-	"screenconnect.com",
-	"oauth-",
-	"order-payment",
-	"order-delivery",
+// maxConcurrentResolves is the max number of destinations of a single request resolved at the same time.
+const maxConcurrentResolves = 4
+
+// WithValidationDeadline returns a context bounding the validation of all destinations of a single
+// request by the resolver timeout, so that resolving them cannot outlast the request.
+func (ss *ShortlinkService) WithValidationDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	if ss.Resolver == nil {
+		return context.WithCancel(ctx)
+	}
 
-	"signin", "sign-in", "login", "log-in",
-	"auth-", "sso-", "verify-account",
-	"confirm-account", "activate-account",
-	"account-verification", "account-suspended",
+	return context.WithTimeout(ctx, ss.Resolver.Timeout)
+}
+
+// ValidateDestination checks a URL and every URL it redirects through against the URL policy,
+// returning the redirect chain if the URL redirects. A URL that cannot be reached is only
+// checked up to the last hop reached.
+func (ss *ShortlinkService) ValidateDestination(ctx context.Context, content string) ([]string, error) {
+	if err := ss.ValidateContent(content); err != nil {
+		return nil, err
+	}
 
-	"payment-", "billing-", "invoice-",
-	"paypal-", "stripe-", "bank-",
-	"refund-", "chargeback", "creditcard",
+	return ss.resolveChain(ctx, content)
+}
 
-	"delivery-", "package-", "shipment-",
-	"fedex-", "ups-", "dhl-", "usps-",
-	"tracking-", "delivered-",
+// validateRedirects resolves the redirect chains of destinations already checked against the
+// URL policy, at most maxConcurrentResolves at a time, and checks every URL they redirect through.
+func (ss *ShortlinkService) validateRedirects(ctx context.Context, destinations []string) error {
+	if ss.Resolver == nil {
+		return nil
+	}
 
-	"support-", "helpdesk-", "tech-support",
-	"microsoft-", "apple-", "google-",
-	"virus-detected", "security-alert",
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	"urgent-", "immediate-", "suspended-",
-	"update-", "renew-", "expire-",
-	"winner-", "congratulations-", "prize-",
+	var (
+		wg        sync.WaitGroup
+		mutex     sync.Mutex
+		firstErr  error
+		semaphore = make(chan struct{}, maxConcurrentResolves)
+	)
 
-	".tk/", ".ml/", ".ga/", ".cf/",
+	for _, destination := range slices.Compact(slices.Sorted(slices.Values(destinations))) {
+		select {
+		case <-ctx.Done(): // past the deadline, leaving the rest checked only themselves
+			wg.Wait()
+			return firstErr
+		case semaphore <- struct{}{}:
+		}
 
-	"bit.ly", "tinyurl.com", "t.co", "goo.gl",
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			if _, err := ss.resolveChain(ctx, destination); err != nil {
+				mutex.Lock()
+				if firstErr == nil {
+					firstErr = err
+					cancel() // no need to resolve the rest
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return firstErr
 }
 
-func (ss *ShortlinkService) ValidateContent(content string) error {
-	contentLower := strings.ToLower(content)
+// resolveChain resolves the redirect chain of a URL and checks every URL it redirects through.
+func (ss *ShortlinkService) resolveChain(ctx context.Context, content string) ([]string, error) {
+	if ss.Resolver == nil {
+		return nil, nil
+	}
 
-	for _, pattern := range suspiciousPatterns {
-		if strings.Contains(contentLower, pattern) {
-			return errors.ErrContentBlocked
+	chain, err := ss.Resolver.Resolve(ctx, content)
+	if err != nil {
+		if stdErrors.Is(err, errors.ErrRedirectChainTooLong) {
+			return nil, err
+		}
+		if ctx.Err() != nil && !stdErrors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, nil // canceled after another destination failed
 		}
+		ss.Logger.Info("failed to resolve redirect chain", log.Str("url", content), log.Str("error", err.Error()))
 	}
 
-	return nil
+	for _, hop := range chain[1:] {
+		if err := ss.ValidateContent(hop); err != nil {
+			return nil, fmt.Errorf("redirect to %s: %w", hop, err)
+		}
+	}
+
+	if len(chain) == 1 {
+		return nil, nil
+	}
+
+	return chain, nil
 }
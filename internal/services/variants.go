@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net/url"
+	"regexp"
+
+	"github.com/ivov/n8n-shortlink/internal/db/entities"
+	"github.com/ivov/n8n-shortlink/internal/errors"
+)
+
+const (
+	minVariants      = 2
+	maxVariants      = 10
+	maxVariantWeight = 100
+)
+
+var variantNameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// ValidateVariants checks if A/B split variants are well-formed and their destinations, along with
+// every URL they redirect through, pass the URL policy.
+func (ss *ShortlinkService) ValidateVariants(ctx context.Context, variants entities.Variants) error {
+	if len(variants) < minVariants || len(variants) > maxVariants {
+		return fmt.Errorf("%w: must have %d to %d variants", errors.ErrVariantsInvalid, minVariants, maxVariants)
+	}
+
+	names := make(map[string]bool, len(variants))
+	destinations := make([]string, 0, len(variants))
+
+	for i, variant := range variants {
+		if !variantNameRegex.MatchString(variant.Name) {
+			return fmt.Errorf("%w: variant %d name must be 1-32 chars of A-Z, a-z, 0-9, -, _", errors.ErrVariantsInvalid, i)
+		}
+
+		if names[variant.Name] {
+			return fmt.Errorf("%w: variant name %q is duplicated", errors.ErrVariantsInvalid, variant.Name)
+		}
+		names[variant.Name] = true
+
+		if variant.Weight < 1 || variant.Weight > maxVariantWeight {
+			return fmt.Errorf("%w: variant %q weight must be 1 to %d", errors.ErrVariantsInvalid, variant.Name, maxVariantWeight)
+		}
+
+		if _, err := url.ParseRequestURI(variant.Destination); err != nil {
+			return fmt.Errorf("%w: variant %q has malformed destination", errors.ErrVariantsInvalid, variant.Name)
+		}
+
+		if err := ss.ValidateContent(variant.Destination); err != nil {
+			return fmt.Errorf("variant %q: %w", variant.Name, err)
+		}
+
+		destinations = append(destinations, variant.Destination)
+	}
+
+	return ss.validateRedirects(ctx, destinations)
+}
+
+// PickVariant returns the variant named by a sticky assignment if it still exists,
+// or else a variant picked at random in proportion to the variants' weights.
+func (ss *ShortlinkService) PickVariant(variants entities.Variants, stickyName string) entities.Variant {
+	total := 0
+	for _, variant := range variants {
+		if stickyName != "" && variant.Name == stickyName {
+			return variant
+		}
+		total += variant.Weight
+	}
+
+	n := rand.IntN(total)
+	for _, variant := range variants {
+		if n < variant.Weight {
+			return variant
+		}
+		n -= variant.Weight
+	}
+
+	return variants[len(variants)-1] // unreachable with positive weights
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"sync"
+
+	"github.com/ivov/n8n-shortlink/internal/db/entities"
+)
+
+// VisitBroker publishes visits to in-process subscribers of their shortlink's slug.
+// Publishing never blocks: a subscriber that falls a full buffer behind is dropped,
+// with its channel closed, so that a slow client cannot hold up visits.
+type VisitBroker struct {
+	bufferSize int
+
+	mutex       sync.Mutex
+	subscribers map[string]map[chan entities.Visit]struct{} // by slug
+	closed      bool
+}
+
+// NewVisitBroker creates a broker buffering up to a number of visits per subscriber.
+func NewVisitBroker(bufferSize int) *VisitBroker {
+	return &VisitBroker{
+		bufferSize:  bufferSize,
+		subscribers: make(map[string]map[chan entities.Visit]struct{}),
+	}
+}
+
+// Subscribe returns a channel receiving the visits to a shortlink, and a func to unsubscribe.
+// The channel is closed on unsubscribing, on falling behind, or on closing the broker.
+func (vb *VisitBroker) Subscribe(slug string) (<-chan entities.Visit, func()) {
+	ch := make(chan entities.Visit, vb.bufferSize)
+
+	vb.mutex.Lock()
+	defer vb.mutex.Unlock()
+
+	if vb.closed {
+		close(ch)
+		return ch, func() {}
+	}
+
+	if vb.subscribers[slug] == nil {
+		vb.subscribers[slug] = make(map[chan entities.Visit]struct{})
+	}
+	vb.subscribers[slug][ch] = struct{}{}
+
+	return ch, func() {
+		vb.mutex.Lock()
+		defer vb.mutex.Unlock()
+		vb.remove(slug, ch)
+	}
+}
+
+// Publish sends a visit to all subscribers of its shortlink, dropping those whose buffer is full.
+func (vb *VisitBroker) Publish(visit entities.Visit) {
+	vb.mutex.Lock()
+	defer vb.mutex.Unlock()
+
+	for ch := range vb.subscribers[visit.Slug] {
+		select {
+		case ch <- visit:
+		default:
+			vb.remove(visit.Slug, ch) // slow consumer
+		}
+	}
+}
+
+// Close closes all subscriptions and rejects new ones, e.g. to end streams on server shutdown.
+func (vb *VisitBroker) Close() {
+	vb.mutex.Lock()
+	defer vb.mutex.Unlock()
+
+	for slug, subscribers := range vb.subscribers {
+		for ch := range subscribers {
+			vb.remove(slug, ch)
+		}
+	}
+
+	vb.closed = true
+}
+
+// remove closes a subscription if still open. Must be called with the mutex held.
+func (vb *VisitBroker) remove(slug string, ch chan entities.Visit) {
+	if _, ok := vb.subscribers[slug][ch]; !ok {
+		return
+	}
+
+	delete(vb.subscribers[slug], ch)
+	if len(vb.subscribers[slug]) == 0 {
+		delete(vb.subscribers, slug)
+	}
+
+	close(ch)
+}
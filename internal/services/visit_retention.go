@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ivov/n8n-shortlink/internal/db/entities"
+	"github.com/ivov/n8n-shortlink/internal/log"
+)
+
+// StartPruning rolls up and prunes visits past retention once per interval until the context is cancelled.
+func (vs *VisitService) StartPruning(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := vs.PruneVisits(ctx); err != nil {
+				vs.Logger.Error(err)
+			}
+		}
+	}
+}
+
+// PruneVisits rolls up visits older than the retention days into daily rollups and deletes them,
+// one day at a time, oldest first, returning the number of visits deleted. Visits are deleted in
+// chunks, each in its own transaction, so that visits can be written in between.
+//
+// A day is rolled up only if it has no rollup yet, so that if pruning is interrupted between rolling
+// up a day and deleting all its visits, the remaining visits are deleted on the next run without
+// being counted twice. Only past days are pruned, so no visits are written to a day after its rollup.
+func (vs *VisitService) PruneVisits(ctx context.Context) (int, error) {
+	cutoff := time.Now().UTC().AddDate(0, 0, -vs.RetentionDays).Format(statsDateLayout)
+
+	var days []string
+	query := "SELECT DISTINCT substr(ts, 1, 10) AS day FROM visits WHERE ts < $1 ORDER BY day;"
+	if err := vs.DB.SelectContext(ctx, &days, query, cutoff); err != nil {
+		return 0, fmt.Errorf("failed to list days to prune: %w", err)
+	}
+
+	total := 0
+
+	for _, day := range days {
+		if err := vs.rollUpDay(ctx, day); err != nil {
+			return total, err
+		}
+
+		deleted, err := vs.deleteDay(ctx, day)
+		total += deleted
+		if err != nil {
+			return total, err
+		}
+
+		vs.Logger.Info("pruned visits", log.Str("day", day), log.Int("count", deleted))
+	}
+
+	return total, nil
+}
+
+// rollUpDay summarizes the visits on a day per slug, and per variant of A/B splits, into rollups.
+func (vs *VisitService) rollUpDay(ctx context.Context, day string) error {
+	start, end, err := dayRange(day)
+	if err != nil {
+		return err
+	}
+
+	var rollups []entities.VisitRollup
+	query := `
+		SELECT
+			slug,
+			COUNT(*) AS count,
+			SUM(is_bot) AS bot_count,
+			COUNT(DISTINCT NULLIF(visitor_hash, '')) AS uniques
+		FROM visits
+		WHERE ts >= $1 AND ts < $2
+		GROUP BY slug;
+	`
+	if err := vs.DB.SelectContext(ctx, &rollups, query, start, end); err != nil {
+		return fmt.Errorf("failed to summarize visits on %s: %w", day, err)
+	}
+
+	var referers []struct {
+		Slug string `db:"slug"`
+		entities.VisitCount
+	}
+	query = `
+		SELECT slug, referer AS value, COUNT(*) AS count
+		FROM visits
+		WHERE ts >= $1 AND ts < $2 AND referer != ''
+		GROUP BY slug, referer;
+	`
+	if err := vs.DB.SelectContext(ctx, &referers, query, start, end); err != nil {
+		return fmt.Errorf("failed to summarize referers on %s: %w", day, err)
+	}
+
+	var variants []entities.VisitVariantRollup
+	query = `
+		SELECT slug, variant, COUNT(*) AS count, SUM(is_bot) AS bot_count
+		FROM visits
+		WHERE ts >= $1 AND ts < $2 AND variant != ''
+		GROUP BY slug, variant;
+	`
+	if err := vs.DB.SelectContext(ctx, &variants, query, start, end); err != nil {
+		return fmt.Errorf("failed to summarize variants on %s: %w", day, err)
+	}
+
+	referersBySlug := make(map[string][]entities.VisitCount)
+	for _, referer := range referers {
+		referersBySlug[referer.Slug] = append(referersBySlug[referer.Slug], referer.VisitCount)
+	}
+
+	tx, err := vs.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin rollup: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }() // no-op after commit
+
+	query = `
+		INSERT INTO visit_daily_rollups (slug, day, count, bot_count, uniques, top_referer, top_referer_count)
+		VALUES (:slug, :day, :count, :bot_count, :uniques, :top_referer, :top_referer_count)
+		ON CONFLICT (slug, day) DO NOTHING;
+	`
+	for _, rollup := range rollups {
+		rollup.Day = day
+		if top := topCounts(referersBySlug[rollup.Slug], refererHost); len(top) > 0 {
+			rollup.TopReferer, rollup.TopRefererCount = top[0].Value, top[0].Count
+		}
+
+		if _, err := tx.NamedExecContext(ctx, query, rollup); err != nil {
+			return fmt.Errorf("failed to save rollup: %w", err)
+		}
+	}
+
+	query = `
+		INSERT INTO visit_daily_variant_rollups (slug, day, variant, count, bot_count)
+		VALUES (:slug, :day, :variant, :count, :bot_count)
+		ON CONFLICT (slug, day, variant) DO NOTHING;
+	`
+	for _, variant := range variants {
+		variant.Day = day
+		if _, err := tx.NamedExecContext(ctx, query, variant); err != nil {
+			return fmt.Errorf("failed to save variant rollup: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit rollup: %w", err)
+	}
+
+	return nil
+}
+
+// deleteDay deletes the visits on a day in chunks, returning the number of visits deleted.
+func (vs *VisitService) deleteDay(ctx context.Context, day string) (int, error) {
+	start, end, err := dayRange(day)
+	if err != nil {
+		return 0, err
+	}
+
+	query := "DELETE FROM visits WHERE id IN (SELECT id FROM visits WHERE ts >= $1 AND ts < $2 LIMIT $3);"
+	total := 0
+
+	for {
+		result, err := vs.DB.ExecContext(ctx, query, start, end, vs.PruneChunkSize)
+		if err != nil {
+			return total, fmt.Errorf("failed to delete visits on %s: %w", day, err)
+		}
+
+		deleted, err := result.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+
+		total += int(deleted)
+
+		if deleted < int64(vs.PruneChunkSize) {
+			return total, nil
+		}
+	}
+}
+
+// dayRange returns the bounds of a day for comparison with visits.ts, start inclusive and end exclusive.
+func dayRange(day string) (string, string, error) {
+	start, err := time.Parse(statsDateLayout, day)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to parse day %q: %w", day, err)
+	}
+
+	return day, start.AddDate(0, 0, 1).Format(statsDateLayout), nil
+}
@@ -1,10 +1,14 @@
 package services
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	"github.com/ivov/n8n-shortlink/internal/db/entities"
+	"github.com/ivov/n8n-shortlink/internal/errors"
 	"github.com/ivov/n8n-shortlink/internal/log"
+	"github.com/ivov/n8n-shortlink/internal/useragent"
 	"github.com/jmoiron/sqlx"
 )
 
@@ -12,33 +16,179 @@ import (
 type VisitService struct {
 	DB     *sqlx.DB
 	Logger *log.Logger
+	// BatchSize is the max number of queued visits written in a single transaction.
+	BatchSize int
+	// FlushInterval is the max duration a queued visit waits to be written.
+	FlushInterval time.Duration
+	// Hasher hashes visitor IPs and user agents to count unique visitors, skipped if nil.
+	Hasher *VisitorHasher
+	// Broker publishes saved visits to live subscribers, skipped if nil.
+	Broker *VisitBroker
+	// RetentionDays is the number of days after which visits are rolled up and pruned.
+	RetentionDays int
+	// PruneChunkSize is the max number of visits deleted in a single transaction when pruning.
+	PruneChunkSize int
+
+	queue chan queuedVisit // nil if visits are written synchronously
+}
+
+type queuedVisit struct {
+	visit entities.Visit
+	kind  string
+}
+
+const insertVisitQuery = `
+	INSERT INTO visits (slug, ts, referer, user_agent, variant, access_path, browser, os, device, is_bot, visitor_hash)
+	VALUES (:slug, :ts, :referer, :user_agent, :variant, :access_path, :browser, :os, :device, :is_bot, :visitor_hash);
+`
+
+// InitQueue makes SaveVisit push visits onto a queue of a max size, to be written in batches by Start,
+// instead of writing them on the request path. Must be called before serving requests.
+func (vs *VisitService) InitQueue(size int) {
+	vs.queue = make(chan queuedVisit, size)
+}
+
+// QueueDepth returns the number of visits waiting to be written.
+func (vs *VisitService) QueueDepth() int {
+	return len(vs.queue)
+}
+
+// SaveVisit timestamps a visit to a shortlink of a kind, classifies its user agent, hashes the visitor's IP
+// and user agent, and writes the visit to the DB, or queues it if there is a queue, then publishes it to
+// live subscribers. The IP itself is not stored. If the queue is full, the visit is dropped with
+// ErrVisitQueueFull, so as not to slow down the request. Queued visits keep the time they happened,
+// not the time they are written, so that they count towards the right day.
+func (vs *VisitService) SaveVisit(visit entities.Visit, kind, ip string) error {
+	visit.TS = entities.CustomTime{Time: time.Now().UTC().Truncate(time.Second)}
+
+	ua := useragent.Parse(visit.UserAgent)
+	visit.Browser, visit.OS, visit.Device, visit.IsBot = ua.Browser, ua.OS, ua.Device, ua.IsBot
+
+	if vs.Hasher != nil {
+		visitorHash, err := vs.Hasher.Hash(ip, visit.UserAgent)
+		if err != nil {
+			return err
+		}
+		visit.VisitorHash = visitorHash
+	}
+
+	if vs.queue == nil {
+		if _, err := vs.DB.NamedExec(insertVisitQuery, visit); err != nil {
+			return fmt.Errorf("failed to save visit: %w", err)
+		}
+
+		vs.logVisit(visit, kind)
+		vs.publish(visit)
+
+		return nil
+	}
+
+	select {
+	case vs.queue <- queuedVisit{visit, kind}:
+		vs.publish(visit)
+		return nil
+	default:
+		return fmt.Errorf("%w: %s", errors.ErrVisitQueueFull, visit.Slug)
+	}
+}
+
+// publish sends a visit to live subscribers.
+func (vs *VisitService) publish(visit entities.Visit) {
+	if vs.Broker == nil {
+		return
+	}
+
+	vs.Broker.Publish(visit)
 }
 
-// SaveVisit writes a visit to the DB.
-func (vs *VisitService) SaveVisit(slug string, kind string, referer string, userAgent string) error {
-	visit := entities.Visit{
-		Slug:      slug,
-		Referer:   referer,
-		UserAgent: userAgent,
+// Start writes queued visits in batches, each in a single transaction, once a batch is full or
+// the flush interval has passed, until the context is cancelled, after which it drains the queue.
+func (vs *VisitService) Start(ctx context.Context) {
+	batch := make([]queuedVisit, 0, vs.BatchSize)
+
+	add := func(queued queuedVisit) {
+		batch = append(batch, queued)
+		if len(batch) >= vs.BatchSize {
+			vs.flush(batch)
+			batch = batch[:0]
+		}
+	}
+
+	ticker := time.NewTicker(vs.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case queued := <-vs.queue:
+			add(queued)
+		case <-ticker.C:
+			vs.flush(batch)
+			batch = batch[:0]
+		case <-ctx.Done():
+			for len(vs.queue) > 0 { // no more visits incoming after server shutdown
+				add(<-vs.queue)
+			}
+			vs.flush(batch)
+			vs.Logger.Info("drained visit queue")
+			return
+		}
+	}
+}
+
+// flush writes a batch of visits in a single transaction. On failure, the batch is logged and discarded.
+func (vs *VisitService) flush(batch []queuedVisit) {
+	if len(batch) == 0 {
+		return
+	}
+
+	if err := vs.insertBatch(batch); err != nil {
+		vs.Logger.Error(err, log.Int("count", len(batch)))
+		return
 	}
 
-	query := `
-		INSERT INTO visits (slug, referer, user_agent)
-		VALUES (:slug, :referer, :user_agent);
-	`
+	for _, queued := range batch {
+		vs.logVisit(queued.visit, queued.kind)
+	}
+}
 
-	_, err := vs.DB.NamedExec(query, visit)
+func (vs *VisitService) insertBatch(batch []queuedVisit) error {
+	tx, err := vs.DB.Beginx()
 	if err != nil {
-		return fmt.Errorf("failed to save visit: %w", err)
+		return fmt.Errorf("failed to begin visit batch: %w", err)
 	}
+	defer func() { _ = tx.Rollback() }() // no-op after commit
 
+	stmt, err := tx.PrepareNamed(insertVisitQuery)
+	if err != nil {
+		return fmt.Errorf("failed to prepare visit batch: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, queued := range batch {
+		if _, err := stmt.Exec(queued.visit); err != nil {
+			return fmt.Errorf("failed to save visit batch: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit visit batch: %w", err)
+	}
+
+	return nil
+}
+
+func (vs *VisitService) logVisit(visit entities.Visit, kind string) {
 	vs.Logger.Info(
 		"user visited shortlink",
 		log.Str("kind", kind),
-		log.Str("slug", slug),
-		log.Str("referer", referer),
-		log.Str("user_agent", userAgent),
+		log.Str("slug", visit.Slug),
+		log.Str("referer", visit.Referer),
+		log.Str("user_agent", visit.UserAgent),
+		log.Str("variant", visit.Variant),
+		log.Str("access_path", visit.AccessPath),
+		log.Str("browser", visit.Browser),
+		log.Str("os", visit.OS),
+		log.Str("device", visit.Device),
+		log.Bool("is_bot", visit.IsBot),
 	)
-
-	return nil
 }
@@ -0,0 +1,223 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ivov/n8n-shortlink/internal/db/entities"
+	"github.com/ivov/n8n-shortlink/internal/useragent"
+)
+
+const (
+	statsDateLayout = "2006-01-02"
+	statsTopLimit   = 10
+)
+
+// CountVisits counts all visits ever to a shortlink, incl. those rolled up, optionally excluding bots.
+// Visit limits (`allowed_visits`) are not enforced yet; when they are, they should count visits here
+// excluding bots, so that a link unfurled in Slack does not use up a one-time link.
+func (vs *VisitService) CountVisits(slug string, excludeBots bool) (int, error) {
+	var count int
+
+	// visits left over on a rolled-up day by interrupted pruning are already counted in its rollup
+	query := `
+		SELECT
+			(
+				SELECT COUNT(*)
+				FROM visits
+				WHERE slug = $1 AND ($2 = 0 OR is_bot = 0)
+					AND substr(ts, 1, 10) NOT IN (SELECT day FROM visit_daily_rollups WHERE slug = $1)
+			) + (
+				SELECT COALESCE(SUM(count - CASE WHEN $2 THEN bot_count ELSE 0 END), 0)
+				FROM visit_daily_rollups
+				WHERE slug = $1
+			);
+	`
+	if err := vs.DB.Get(&count, query, slug, excludeBots); err != nil {
+		return 0, fmt.Errorf("failed to count visits: %w", err)
+	}
+
+	return count, nil
+}
+
+// GetStats summarizes the visits to a shortlink between two days, both inclusive, in UTC,
+// optionally excluding bots. Daily counts, uniques, top referers and variants include rolled-up
+// visits, while top user agents and access paths cover only visits within retention.
+func (vs *VisitService) GetStats(slug string, from, to time.Time, excludeBots bool) (*entities.VisitStats, error) {
+	stats := &entities.VisitStats{
+		From:        from.Format(statsDateLayout),
+		To:          to.Format(statsDateLayout),
+		AccessPaths: make(map[string]int),
+		Variants:    make(map[string]int),
+	}
+
+	total, err := vs.CountVisits(slug, excludeBots)
+	if err != nil {
+		return nil, err
+	}
+	stats.Total = total
+
+	// visits.ts is a `YYYY-MM-DD HH:MM:SS` UTC timestamp, so the range is a string comparison on the index
+	start := from.Format(statsDateLayout)
+	end := to.AddDate(0, 0, 1).Format(statsDateLayout)
+
+	var daily []entities.DailyVisits
+	query := `
+		SELECT
+			substr(ts, 1, 10) AS date,
+			COUNT(*) AS count,
+			COUNT(DISTINCT NULLIF(visitor_hash, '')) AS uniques
+		FROM visits
+		WHERE slug = $1 AND ts >= $2 AND ts < $3 AND ($4 = 0 OR is_bot = 0)
+		GROUP BY date;
+	`
+	if err := vs.DB.Select(&daily, query, slug, start, end, excludeBots); err != nil {
+		return nil, fmt.Errorf("failed to count daily visits: %w", err)
+	}
+
+	var rollups []entities.VisitRollup
+	query = `
+		SELECT
+			slug, day, count, bot_count, uniques, top_referer, top_referer_count
+		FROM visit_daily_rollups
+		WHERE slug = $1 AND day >= $2 AND day < $3;
+	`
+	if err := vs.DB.Select(&rollups, query, slug, start, end); err != nil {
+		return nil, fmt.Errorf("failed to get visit rollups: %w", err)
+	}
+
+	dailyByDate := make(map[string]entities.DailyVisits, len(daily)+len(rollups))
+	for _, day := range daily {
+		dailyByDate[day.Date] = day
+	}
+
+	for _, rollup := range rollups {
+		count := rollup.Count
+		if excludeBots {
+			count -= rollup.BotCount
+		}
+		dailyByDate[rollup.Day] = entities.DailyVisits{Date: rollup.Day, Count: count, Uniques: rollup.Uniques} // overrides leftovers
+	}
+
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		date := day.Format(statsDateLayout)
+		stats.Daily = append(stats.Daily, entities.DailyVisits{
+			Date:    date,
+			Count:   dailyByDate[date].Count,
+			Uniques: dailyByDate[date].Uniques,
+		})
+	}
+
+	var referers []entities.VisitCount
+	query = `
+		SELECT referer AS value, COUNT(*) AS count
+		FROM visits
+		WHERE slug = $1 AND ts >= $2 AND ts < $3 AND ($4 = 0 OR is_bot = 0) AND referer != ''
+		GROUP BY referer;
+	`
+	if err := vs.DB.Select(&referers, query, slug, start, end, excludeBots); err != nil {
+		return nil, fmt.Errorf("failed to count referers: %w", err)
+	}
+
+	for _, rollup := range rollups {
+		if rollup.TopReferer != "" {
+			referers = append(referers, entities.VisitCount{Value: rollup.TopReferer, Count: rollup.TopRefererCount})
+		}
+	}
+
+	stats.TopReferers = topCounts(referers, refererHost)
+
+	var userAgents []entities.VisitCount
+	query = `
+		SELECT user_agent AS value, COUNT(*) AS count
+		FROM visits
+		WHERE slug = $1 AND ts >= $2 AND ts < $3 AND ($4 = 0 OR is_bot = 0)
+		GROUP BY user_agent;
+	`
+	if err := vs.DB.Select(&userAgents, query, slug, start, end, excludeBots); err != nil {
+		return nil, fmt.Errorf("failed to count user agents: %w", err)
+	}
+
+	stats.TopUserAgents = topCounts(userAgents, useragent.Family)
+
+	var accessPaths []entities.VisitCount
+	query = `
+		SELECT access_path AS value, COUNT(*) AS count
+		FROM visits
+		WHERE slug = $1 AND ts >= $2 AND ts < $3 AND ($4 = 0 OR is_bot = 0)
+		GROUP BY access_path;
+	`
+	if err := vs.DB.Select(&accessPaths, query, slug, start, end, excludeBots); err != nil {
+		return nil, fmt.Errorf("failed to count access paths: %w", err)
+	}
+
+	for _, accessPath := range accessPaths {
+		if accessPath.Value == "" {
+			accessPath.Value = "unknown" // predating access paths
+		}
+		stats.AccessPaths[accessPath.Value] += accessPath.Count
+	}
+
+	var variants []entities.VisitCount
+	query = `
+		SELECT variant AS value, COUNT(*) AS count
+		FROM visits
+		WHERE slug = $1 AND ts >= $2 AND ts < $3 AND ($4 = 0 OR is_bot = 0) AND variant != ''
+			AND substr(ts, 1, 10) NOT IN (SELECT day FROM visit_daily_rollups WHERE slug = $1)
+		GROUP BY variant
+		UNION ALL
+		SELECT variant AS value, SUM(count - CASE WHEN $4 THEN bot_count ELSE 0 END) AS count
+		FROM visit_daily_variant_rollups
+		WHERE slug = $1 AND day >= $2 AND day < $3
+		GROUP BY variant;
+	`
+	if err := vs.DB.Select(&variants, query, slug, start, end, excludeBots); err != nil {
+		return nil, fmt.Errorf("failed to count variants: %w", err)
+	}
+
+	for _, variant := range variants {
+		stats.Variants[variant.Value] += variant.Count
+	}
+
+	return stats, nil
+}
+
+// topCounts regroups counts by a derived value, e.g. a referer by its host, and returns the
+// highest ones, breaking ties alphabetically.
+func topCounts(counts []entities.VisitCount, group func(string) string) []entities.VisitCount {
+	grouped := make(map[string]int)
+	for _, count := range counts {
+		grouped[group(count.Value)] += count.Count
+	}
+
+	top := make([]entities.VisitCount, 0, len(grouped))
+	for value, count := range grouped {
+		top = append(top, entities.VisitCount{Value: value, Count: count})
+	}
+
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].Value < top[j].Value
+	})
+
+	if len(top) > statsTopLimit {
+		top = top[:statsTopLimit]
+	}
+
+	return top
+}
+
+// refererHost returns the lowercased host of a referer, or the referer itself if it has none.
+func refererHost(referer string) string {
+	u, err := url.Parse(referer)
+	if err != nil || u.Hostname() == "" {
+		return referer
+	}
+
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
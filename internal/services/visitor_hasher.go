@@ -0,0 +1,79 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const visitorSaltLength = 32
+
+// VisitorHasher hashes client identifiers, e.g. IP and user agent, with a salt that rotates daily,
+// so that visitors can be counted as unique within a day without storing their IPs. Only the
+// current day's salt is kept in the DB, shared across restarts, so past hashes cannot be recomputed
+// or linked across days.
+type VisitorHasher struct {
+	DB *sqlx.DB
+
+	mutex sync.Mutex
+	day   string // UTC day of the cached salt, e.g. 2026-01-31
+	salt  []byte
+}
+
+// Hash returns the hex-encoded HMAC-SHA256 of the values, keyed with the current day's salt.
+func (vh *VisitorHasher) Hash(values ...string) (string, error) {
+	salt, err := vh.currentSalt()
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, salt)
+	for _, value := range values {
+		mac.Write([]byte(value))
+		mac.Write([]byte{0}) // separator, so that ("ab", "c") and ("a", "bc") differ
+	}
+
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// currentSalt returns the salt of the current UTC day, creating it and deleting all
+// previous ones on the first call of the day.
+func (vh *VisitorHasher) currentSalt() ([]byte, error) {
+	vh.mutex.Lock()
+	defer vh.mutex.Unlock()
+
+	today := time.Now().UTC().Format(statsDateLayout)
+	if vh.day == today {
+		return vh.salt, nil
+	}
+
+	salt := make([]byte, visitorSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate visitor salt: %w", err)
+	}
+
+	// another instance may have created today's salt already
+	query := "INSERT INTO visitor_salts (day, salt) VALUES ($1, $2) ON CONFLICT (day) DO NOTHING;"
+	if _, err := vh.DB.Exec(query, today, salt); err != nil {
+		return nil, fmt.Errorf("failed to save visitor salt: %w", err)
+	}
+
+	if err := vh.DB.Get(&salt, "SELECT salt FROM visitor_salts WHERE day = $1;", today); err != nil {
+		return nil, fmt.Errorf("failed to get visitor salt: %w", err)
+	}
+
+	if _, err := vh.DB.Exec("DELETE FROM visitor_salts WHERE day < $1;", today); err != nil {
+		return nil, fmt.Errorf("failed to delete previous visitor salts: %w", err)
+	}
+
+	vh.day = today
+	vh.salt = salt
+
+	return salt, nil
+}
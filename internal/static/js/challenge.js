@@ -4,8 +4,6 @@ modal.style.display = "flex";
 void modal.offsetWidth;
 modal.classList.add("show");
 
-const base64 = btoa;
-
 const passwordInput = document.getElementById("required-password-input");
 
 passwordInput.focus();
@@ -14,31 +12,27 @@ passwordInput.addEventListener("keypress", async (event) => {
   if (event.key === "Enter") {
     event.preventDefault();
 
-    const slug = window.location.pathname.split("/").pop();
+    const slug = window.location.pathname.split("/")[1]; // also for /{slug}/view
     const plaintextPassword = passwordInput.value;
 
-    const response = await fetch(`/${slug}`, {
-      method: "GET",
-      headers: { Authorization: `Basic ${base64(plaintextPassword)}` },
+    const response = await fetch(`/${slug}/unlock`, {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ password: plaintextPassword, session_only: true }),
     });
 
     if (response.status === 200) {
-      const data = await response.json();
-
-      if (data.url) {
-        window.location.href = data.url;
-      } else {
-        const json = JSON.stringify(data, null, 2);
-        const blob = new Blob([json], { type: "application/json" });
-        const url = URL.createObjectURL(blob);
-        window.open(url, "_blank");
-        URL.revokeObjectURL(url);
-      }
+      window.location.reload(); // unlocked by cookie set in response, recording the visit on reload
       return;
     }
 
-    if (response.status === 401) {
-      document.querySelector(".invalid-password").style.display = "block";
+    if (response.status === 401 || response.status === 429) {
+      const invalidPassword = document.querySelector(".invalid-password");
+      invalidPassword.textContent =
+        response.status === 429
+          ? `Too many attempts. Try again in ${response.headers.get("Retry-After")}s!`
+          : "Invalid password. Try again!";
+      invalidPassword.style.display = "block";
       document.querySelector(".password-tip").style.display = "none";
       return;
     }
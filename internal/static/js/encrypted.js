@@ -0,0 +1,147 @@
+// Decrypts an encrypted shortlink in the browser. The blob is `v1.` followed by the base64url-encoded
+// 12-byte IV and AES-256-GCM ciphertext, and the key is the base64url-encoded 32 bytes in the URL fragment,
+// which browsers never send to the server.
+
+const message = document.getElementById("encrypted-message");
+const status = document.getElementById("encrypted-status");
+
+const BLOB_VERSION = "v1.";
+const IV_LENGTH = 12;
+
+function decodeBase64Url(encoded) {
+  const base64 = encoded.replace(/-/g, "+").replace(/_/g, "/");
+  const padded = base64 + "=".repeat((4 - (base64.length % 4)) % 4);
+
+  return Uint8Array.from(atob(padded), (char) => char.charCodeAt(0));
+}
+
+async function decryptBlob(blob, encodedKey) {
+  if (!blob.startsWith(BLOB_VERSION)) {
+    throw new Error("unsupported blob version");
+  }
+
+  const bytes = decodeBase64Url(blob.slice(BLOB_VERSION.length));
+  const key = await crypto.subtle.importKey(
+    "raw",
+    decodeBase64Url(encodedKey),
+    "AES-GCM",
+    false,
+    ["decrypt"],
+  );
+
+  const plaintext = await crypto.subtle.decrypt(
+    { name: "AES-GCM", iv: bytes.slice(0, IV_LENGTH) },
+    key,
+    bytes.slice(IV_LENGTH),
+  );
+
+  return new TextDecoder().decode(plaintext);
+}
+
+// Renders a workflow's nodes and connections as SVG in this page, so that the decrypted workflow never
+// leaves it, unlike the demo component, which renders in a third-party frame.
+
+const SVG_NS = "http://www.w3.org/2000/svg";
+const NODE_WIDTH = 200;
+const NODE_HEIGHT = 60;
+const CANVAS_PADDING = 40;
+
+function createSvgElement(name, attributes = {}) {
+  const element = document.createElementNS(SVG_NS, name);
+  for (const [key, value] of Object.entries(attributes)) {
+    element.setAttribute(key, value);
+  }
+
+  return element;
+}
+
+function renderWorkflow(workflow) {
+  const nodes = Array.isArray(workflow.nodes) ? workflow.nodes : [];
+  const positions = new Map();
+
+  nodes.forEach((node, i) => {
+    const [x, y] = Array.isArray(node.position) ? node.position : [i * (NODE_WIDTH + CANVAS_PADDING), 0];
+    positions.set(node.name, { x: Number(x) || 0, y: Number(y) || 0 });
+  });
+
+  const xs = [...positions.values()].map((p) => p.x);
+  const ys = [...positions.values()].map((p) => p.y);
+  const minX = Math.min(0, ...xs) - CANVAS_PADDING;
+  const minY = Math.min(0, ...ys) - CANVAS_PADDING;
+  const width = Math.max(0, ...xs) + NODE_WIDTH + CANVAS_PADDING - minX;
+  const height = Math.max(0, ...ys) + NODE_HEIGHT + CANVAS_PADDING - minY;
+
+  const svg = createSvgElement("svg", {
+    class: "workflow-canvas",
+    viewBox: `${minX} ${minY} ${width} ${height}`,
+    role: "img",
+    "aria-label": "Workflow",
+  });
+
+  for (const [source, outputs] of Object.entries(workflow.connections ?? {})) {
+    const from = positions.get(source);
+    if (!from) continue;
+
+    for (const targets of Object.values(outputs ?? {}).flat()) {
+      for (const target of targets ?? []) {
+        const to = positions.get(target?.node);
+        if (!to) continue;
+
+        const x1 = from.x + NODE_WIDTH;
+        const y1 = from.y + NODE_HEIGHT / 2;
+        const x2 = to.x;
+        const y2 = to.y + NODE_HEIGHT / 2;
+        const bend = Math.max(40, Math.abs(x2 - x1) / 2);
+
+        svg.append(
+          createSvgElement("path", {
+            class: "workflow-connection",
+            d: `M ${x1} ${y1} C ${x1 + bend} ${y1}, ${x2 - bend} ${y2}, ${x2} ${y2}`,
+          }),
+        );
+      }
+    }
+  }
+
+  for (const node of nodes) {
+    const { x, y } = positions.get(node.name);
+    const group = createSvgElement("g", { class: "workflow-node", transform: `translate(${x} ${y})` });
+
+    group.append(createSvgElement("rect", { width: NODE_WIDTH, height: NODE_HEIGHT, rx: 8 }));
+
+    const name = createSvgElement("text", { class: "workflow-node-name", x: 12, y: 25 });
+    name.textContent = String(node.name ?? "");
+
+    const type = createSvgElement("text", { class: "workflow-node-type", x: 12, y: 45 });
+    type.textContent = String(node.type ?? "").replace(/^n8n-nodes-base\./, "");
+
+    group.append(name, type);
+    svg.append(group);
+  }
+
+  return svg;
+}
+
+async function showWorkflow() {
+  const encodedKey = window.location.hash.slice(1);
+
+  if (!encodedKey) {
+    status.textContent =
+      "This link is missing its key. Ask the sender for the full link, including the part after #.";
+    return;
+  }
+
+  try {
+    const workflow = JSON.parse(await decryptBlob(message.dataset.blob, encodedKey)); // reject if not a workflow
+
+    const canvas = renderWorkflow(workflow);
+
+    message.remove();
+    document.body.classList.add("decrypted");
+    document.body.prepend(canvas);
+  } catch {
+    status.textContent = "This workflow could not be decrypted. Check that the link is complete.";
+  }
+}
+
+showWorkflow();
@@ -1,5 +1,6 @@
 const vanityUrlToggle = document.getElementById("vanity-url-toggle");
 const passwordToggle = document.getElementById("password-toggle");
+const encryptToggle = document.getElementById("encrypt-toggle");
 
 const workflowInput = document.getElementById("workflow-input");
 const vanityUrlInput = document.getElementById("vanity-url-input");
@@ -20,11 +21,13 @@ const ERROR_CODES_TO_MESSAGES = {
   PASSWORD_TOO_SHORT: "Too short! Min 8 chars",
   PAYLOAD_TOO_LARGE: "Too large! Max 5 MB",
   CONTENT_BLOCKED: "Content blocked - suspicious URL detected",
+  ENCRYPTED_BLOB_INVALID: "Too large to encrypt! Max 3 MB",
 };
 
 function resetForm() {
   vanityUrlToggle.checked = false;
   passwordToggle.checked = false;
+  encryptToggle.checked = false;
 
   vanityUrlInput.value = "";
   passwordInput.value = "";
@@ -148,6 +151,21 @@ async function handleSubmit(event) {
     return;
   }
 
+  // the key stays in the URL fragment, so the server only ever sees the encrypted blob
+  let encodedKey = "";
+
+  if (encryptToggle.checked) {
+    if (kind !== "workflow") {
+      workflowErrorMsg.textContent = "Only workflows can be encrypted!";
+      workflowErrorMsg.style.visibility = "visible";
+      return;
+    }
+
+    kind = "encrypted";
+    ({ blob: jsonData.content, encodedKey } = await encryptWorkflow(jsonData.content));
+    jsonData.kind = kind;
+  }
+
   const response = await fetch(form.action, {
     method: form.method,
     headers: {
@@ -160,7 +178,8 @@ async function handleSubmit(event) {
 
   if (response.ok) {
     // console.log("Success:", jsonResponse); // @TODO: Remove
-    showModal(jsonResponse.data.slug, kind, jsonData.password !== undefined);
+    const slug = encodedKey ? `${jsonResponse.data.slug}#${encodedKey}` : jsonResponse.data.slug;
+    showModal(slug, kind, jsonData.password !== undefined);
     throwConfetti();
     return;
   }
@@ -177,7 +196,9 @@ async function handleSubmit(event) {
   }
 
   if (
-    (errorCode.startsWith("CONTENT") || errorCode.startsWith("PAYLOAD")) &&
+    (errorCode.startsWith("CONTENT") ||
+      errorCode.startsWith("PAYLOAD") ||
+      errorCode.startsWith("ENCRYPTED")) &&
     errorMsg !== undefined
   ) {
     workflowErrorMsg.textContent = errorMsg;
@@ -186,6 +207,35 @@ async function handleSubmit(event) {
   }
 }
 
+// Encrypts a workflow with AES-256-GCM under a new random key into a blob of `v1.` followed by the
+// base64url-encoded IV and ciphertext, as expected by the server and decrypted by encrypted.js.
+async function encryptWorkflow(workflow) {
+  const rawKey = crypto.getRandomValues(new Uint8Array(32));
+  const iv = crypto.getRandomValues(new Uint8Array(12));
+
+  const key = await crypto.subtle.importKey("raw", rawKey, "AES-GCM", false, [
+    "encrypt",
+  ]);
+  const ciphertext = await crypto.subtle.encrypt(
+    { name: "AES-GCM", iv },
+    key,
+    new TextEncoder().encode(workflow),
+  );
+
+  const bytes = new Uint8Array(iv.length + ciphertext.byteLength);
+  bytes.set(iv);
+  bytes.set(new Uint8Array(ciphertext), iv.length);
+
+  return { blob: `v1.${encodeBase64Url(bytes)}`, encodedKey: encodeBase64Url(rawKey) };
+}
+
+function encodeBase64Url(bytes) {
+  let binary = "";
+  for (const byte of bytes) binary += String.fromCharCode(byte);
+
+  return btoa(binary).replace(/\+/g, "-").replace(/\//g, "_").replace(/=+$/, "");
+}
+
 const isUrl = (str) => {
   try {
     new URL(str);
@@ -226,6 +276,11 @@ function showModal(shortlink, kind, hasPassword = false) {
       "Your password-protected shortlink has been created.";
     successTip.innerHTML =
       "Accessing this workflow will require your password.";
+  } else if (kind === "encrypted") {
+    successMessage.textContent =
+      "Your workflow was encrypted in your browser.";
+    successTip.innerHTML =
+      "Share the full link, including the key after <code>#</code>. We cannot recover it.";
   }
 
   shortlinkText.textContent = `https://n8n.to/${shortlink}`;
@@ -0,0 +1,149 @@
+package useragent
+
+import (
+	"regexp"
+	"strings"
+)
+
+const (
+	// DeviceMobile is the device class of phones and tablets.
+	DeviceMobile = "mobile"
+	// DeviceDesktop is the device class of all other devices.
+	DeviceDesktop = "desktop"
+)
+
+var mobileMarkers = []string{
+	"mobi", "android", "iphone", "ipad", "ipod",
+	"windows phone", "blackberry", "opera mini", "silk/",
+}
+
+// DeviceClass classifies a user agent as mobile (incl. tablets) or desktop.
+func DeviceClass(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+
+	for _, marker := range mobileMarkers {
+		if strings.Contains(ua, marker) {
+			return DeviceMobile
+		}
+	}
+
+	return DeviceDesktop
+}
+
+// Info is a user agent classified by browser, OS and device class, and whether it is a bot.
+type Info struct {
+	Browser string // browser or client family, e.g. "Chrome" or "curl", or the name of a bot, e.g. "Slackbot"
+	OS      string // e.g. "Windows" or "iOS"
+	Device  string // DeviceMobile or DeviceDesktop
+	IsBot   bool   // crawler, link-preview fetcher or uptime monitor
+}
+
+// pattern names a bot, browser or OS identified by any of its lowercase markers.
+type pattern struct {
+	name    string
+	markers []string
+}
+
+// bots are checked in order, and before generic bot markers.
+var bots = []pattern{
+	// link-preview crawlers
+	{"Slackbot", []string{"slackbot", "slack-imgproxy"}},
+	{"Discordbot", []string{"discordbot"}},
+	{"Twitterbot", []string{"twitterbot"}},
+	{"TelegramBot", []string{"telegrambot"}},
+	{"WhatsApp", []string{"whatsapp"}},
+	{"LinkedInBot", []string{"linkedinbot"}},
+	{"Facebook", []string{"facebookexternalhit", "facebookcatalog"}},
+	// uptime monitors
+	{"UptimeRobot", []string{"uptimerobot"}},
+	{"Pingdom", []string{"pingdom"}},
+	{"StatusCake", []string{"statuscake"}},
+	{"Better Uptime", []string{"better uptime", "betteruptime"}},
+	{"Site24x7", []string{"site24x7"}},
+	{"Checkly", []string{"checkly"}},
+	{"Datadog", []string{"datadog"}},
+	{"New Relic", []string{"newrelicpinger"}},
+	{"n8n-shortlink", []string{"n8n-shortlink-health-check"}},
+	// search engines and others
+	{"Googlebot", []string{"googlebot"}},
+	{"bingbot", []string{"bingbot"}},
+}
+
+// genericBot matches generic bot markers as whole words, e.g. "bot" or "crawler", or at the end of a
+// product name before its version, e.g. "AhrefsBot/7.0", so that names merely containing a marker,
+// e.g. Cubot phones, are not flagged.
+var genericBot = regexp.MustCompile(
+	`(^|[^a-z0-9])(bot|crawl|crawler|spider|slurp|preview|headlesschrome)([^a-z0-9]|$)|[a-z0-9](bot|crawler|spider)[/;]`,
+)
+
+// families are checked in order, since user agents also name the browsers they derive from,
+// e.g. Edge also names Chrome and Safari.
+var families = []pattern{
+	{"curl", []string{"curl/"}},
+	{"n8n", []string{"n8n"}},
+	{"Edge", []string{"edg/", "edge/"}},
+	{"Opera", []string{"opr/", "opera"}},
+	{"Samsung Internet", []string{"samsungbrowser/"}},
+	{"Firefox", []string{"firefox/", "fxios/"}},
+	{"Chrome", []string{"chrome/", "crios/", "chromium/"}},
+	{"Safari", []string{"safari/"}},
+}
+
+// oses are checked in order, since user agents also name the OSes they derive from or resemble,
+// e.g. iOS also names Mac OS X and Android also names Linux.
+var oses = []pattern{
+	{"Windows", []string{"windows"}},
+	{"iOS", []string{"iphone", "ipad", "ipod"}},
+	{"macOS", []string{"macintosh", "mac os x"}},
+	{"Android", []string{"android"}},
+	{"ChromeOS", []string{"cros "}},
+	{"Linux", []string{"linux"}},
+}
+
+// Parse classifies a user agent by browser, OS and device class, and flags bots,
+// falling back to "Other" for an unknown browser or OS.
+func Parse(userAgent string) Info {
+	ua := strings.ToLower(userAgent)
+
+	info := Info{Browser: "Other", OS: "Other", Device: DeviceClass(userAgent)}
+
+	if name, ok := match(ua, bots); ok {
+		info.Browser = name
+		info.IsBot = true
+	} else if genericBot.MatchString(ua) {
+		info.Browser = "Bot"
+		info.IsBot = true
+	} else if name, ok := match(ua, families); ok {
+		info.Browser = name
+	}
+
+	if name, ok := match(ua, oses); ok {
+		info.OS = name
+	}
+
+	return info
+}
+
+// Family classifies a user agent by browser or client family, e.g. "Chrome", grouping all
+// bots as "Bot" and falling back to "Other".
+func Family(userAgent string) string {
+	info := Parse(userAgent)
+	if info.IsBot {
+		return "Bot"
+	}
+
+	return info.Browser
+}
+
+// match returns the name of the first pattern with a marker contained in a lowercased user agent.
+func match(ua string, patterns []pattern) (string, bool) {
+	for _, pattern := range patterns {
+		for _, marker := range pattern.markers {
+			if strings.Contains(ua, marker) {
+				return pattern.name, true
+			}
+		}
+	}
+
+	return "", false
+}